@@ -2,10 +2,12 @@ package main
 
 import (
 	"context"
+	"encoding/json"
 	"flag"
 	"fmt"
 	"image/png"
 	"os"
+	"path/filepath"
 	"time"
 
 	"github.com/golang/geo/r3"
@@ -46,6 +48,14 @@ func realMain() error {
 		logger.SetLevel(logging.DEBUG)
 	}
 
+	if flag.Arg(0) == "schema" {
+		return printSchemas(flag.Arg(1))
+	}
+
+	if flag.Arg(0) == "replan" {
+		return replan(ctx, flag.Arg(1), n, logger)
+	}
+
 	if *configFile == "" {
 		return fmt.Errorf("need a config file")
 	}
@@ -134,6 +144,19 @@ func realMain() error {
 		return vc.FullDemo(ctx)
 	case "full-demo-wait":
 		return vc.WaitForCupAndGo(ctx)
+	case "resume":
+		return vc.Resume(ctx)
+	case "dry-run":
+		res, err := vc.DryRun(ctx)
+		if err != nil {
+			return err
+		}
+		for _, s := range res["steps"].([]interface{}) {
+			m := s.(map[string]interface{})
+			logger.Infof("%v ok: %v l2: %v err: %v", m["step"], m["ok"], m["l2"], m["error"])
+		}
+		logger.Infof("dry run ok: %v", res["ok"])
+		return nil
 	case "find-cups":
 		cups, err := vc.FindCups(ctx)
 		if err != nil {
@@ -188,6 +211,64 @@ func realMain() error {
 	}
 }
 
+// printSchemas writes the JSON Schema for model's config, or all of them if model is "".
+func printSchemas(model string) error {
+	all, err := pour.ConfigSchemas()
+	if err != nil {
+		return err
+	}
+
+	var out interface{} = all
+	if model != "" {
+		s, ok := all[model]
+		if !ok {
+			return fmt.Errorf("no model %q", model)
+		}
+		out = s
+	}
+
+	data, err := json.MarshalIndent(out, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Println(string(data))
+	return err
+}
+
+// replan plans recorded plans again n times each, fn is a record or a
+// directory of them, and says how each compares to what was recorded.
+func replan(ctx context.Context, fn string, n int, logger logging.Logger) error {
+	if fn == "" {
+		return fmt.Errorf("replan needs a plan record file or directory")
+	}
+	files, err := pour.PlanRecordFiles(fn)
+	if err != nil {
+		return err
+	}
+
+	planned, changed := 0, 0
+	for _, f := range files {
+		r, err := pour.ReadPlanRecord(f)
+		if err != nil {
+			return err
+		}
+		for i := 0; i < n; i++ {
+			res, err := pour.Replan(ctx, logger, r)
+			if err != nil {
+				logger.Warnf("%s: %v", filepath.Base(f), err)
+				break
+			}
+			planned++
+			if res.OutcomeChanged() {
+				changed++
+			}
+			logger.Infof("%s: %v", filepath.Base(f), res)
+		}
+	}
+	logger.Infof("replanned %d times, %d with a different outcome", planned, changed)
+	return nil
+}
+
 func getAPose(ctx context.Context, client robot.Robot, poseTracker, name string) (*referenceframe.PoseInFrame, error) {
 	pt, err := posetracker.FromRobot(client, poseTracker)
 	if err != nil {
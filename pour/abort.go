@@ -0,0 +1,97 @@
+package pour
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"go.uber.org/multierr"
+)
+
+// opTracker hands out contexts for everything VinoCart runs that can move an
+// arm, so an abort can cancel all of them at once.
+type opTracker struct {
+	lock    sync.Mutex
+	nextID  int
+	cancels map[int]context.CancelFunc
+}
+
+func newOpTracker() *opTracker {
+	return &opTracker{cancels: map[int]context.CancelFunc{}}
+}
+
+// track returns a child of ctx that cancelAll will cancel, and a func to call when done with it.
+func (ot *opTracker) track(ctx context.Context) (context.Context, func()) {
+	ctx, cancel := context.WithCancel(ctx)
+
+	ot.lock.Lock()
+	defer ot.lock.Unlock()
+	ot.nextID++
+	id := ot.nextID
+	ot.cancels[id] = cancel
+
+	return ctx, func() {
+		cancel()
+		ot.lock.Lock()
+		defer ot.lock.Unlock()
+		delete(ot.cancels, id)
+	}
+}
+
+func (ot *opTracker) cancelAll() int {
+	ot.lock.Lock()
+	defer ot.lock.Unlock()
+	for _, c := range ot.cancels {
+		c()
+	}
+	return len(ot.cancels)
+}
+
+var errFaulted = fmt.Errorf("faulted, need clear_fault before moving")
+
+func (vc *VinoCart) checkNotFaulted() error {
+	if vc.getStatus() == StateFaulted {
+		return errFaulted
+	}
+	return nil
+}
+
+// Abort cancels everything in flight, stops both arms and latches the fault
+// state until ClearFault is called.
+func (vc *VinoCart) Abort(ctx context.Context, reason string) error {
+	stage := ""
+	vc.recordCycle(func(r *cycleRecord) {
+		stage = r.currentStage
+	})
+
+	fault := vc.state.latchFault(reason, stage)
+
+	n := vc.ops.cancelAll()
+	vc.logger.Warnf("abort (%s) canceled %d operations, state was %v stage was %q", reason, n, fault.State, stage)
+
+	return multierr.Combine(vc.c.Arm.Stop(ctx, nil), vc.c.BottleArm.Stop(ctx, nil))
+}
+
+func (vc *VinoCart) ClearFault() error {
+	next := StateManual
+	if vc.loop.active() {
+		next = StateStandby
+	}
+	err := vc.state.clearFault(next)
+	if err != nil {
+		return err
+	}
+	vc.logger.Infof("fault cleared")
+	return nil
+}
+
+// waitWhileFaulted blocks the loop until the fault is cleared.
+func (vc *VinoCart) waitWhileFaulted(ctx context.Context) {
+	for ctx.Err() == nil && vc.checkNotFaulted() != nil {
+		select {
+		case <-ctx.Done():
+		case <-time.After(250 * time.Millisecond):
+		}
+	}
+}
@@ -0,0 +1,200 @@
+package pour
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"runtime"
+	"sync"
+	"time"
+
+	"go.uber.org/multierr"
+
+	"go.viam.com/rdk/motionplan"
+	"go.viam.com/rdk/motionplan/armplanning"
+	"go.viam.com/rdk/referenceframe"
+	"go.viam.com/rdk/spatialmath"
+	viz "go.viam.com/rdk/vision"
+)
+
+// an approach with less clearance than this costs an extra radian of travel per
+// approachClearanceMMPerRad it's short by
+const (
+	approachWantClearanceMM   = 50
+	approachClearanceMMPerRad = 10
+)
+
+// approachCandidate is one way of approaching a cup, planned but not moved.
+type approachCandidate struct {
+	o    *spatialmath.OrientationVectorDegrees
+	pose *referenceframe.PoseInFrame
+
+	trajectory [][]referenceframe.Input // cup arm joints
+	travel     float64                  // joint travel, in radians
+	clearance  float64                  // closest the arm and gripper get to an obstacle, in mm
+	err        error
+}
+
+// score is lower for better approaches.
+func (ac *approachCandidate) score() float64 {
+	s := ac.travel
+	if ac.clearance < approachWantClearanceMM {
+		s += (approachWantClearanceMM - ac.clearance) / approachClearanceMMPerRad
+	}
+	return s
+}
+
+func (ac *approachCandidate) String() string {
+	if ac.err != nil {
+		return fmt.Sprintf("%v rejected: %v", ac.o, ac.err)
+	}
+	return fmt.Sprintf("%v travel: %.2f clearance: %.0fmm score: %.2f", ac.o, ac.travel, ac.clearance, ac.score())
+}
+
+func (cfg *Config) cpuThreads() int {
+	if cfg.CPUThreads > 0 {
+		return cfg.CPUThreads
+	}
+	return max(1, runtime.NumCPU()/2)
+}
+
+func (cfg *Config) approachTimeout() time.Duration {
+	if cfg.ApproachTimeoutSeconds > 0 {
+		return time.Duration(cfg.ApproachTimeoutSeconds * float64(time.Second))
+	}
+	return 20 * time.Second
+}
+
+// planApproaches plans an approach to obj from every choice at once, at most
+// cpu_threads at a time, treating others and the bottle arm as obstacles. The
+// arms start from start, or where they are now if it's nil.
+func (vc *VinoCart) planApproaches(ctx context.Context, tag string, start referenceframe.FrameSystemInputs, obj *viz.Object, others []*viz.Object, choices []*spatialmath.OrientationVectorDegrees) ([]*approachCandidate, error) {
+	fs, err := vc.cartFrameSystem(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if start == nil {
+		start, err = vc.armInputs(ctx, fs)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		start = withStaticInputs(fs, start)
+	}
+	worldState, err := vc.planWorldState(ctx, fs, vc.pickObstacles(obj, others)...)
+	if err != nil {
+		return nil, err
+	}
+
+	candidates := []*approachCandidate{}
+	for _, o := range choices {
+		candidates = append(candidates, &approachCandidate{o: o, pose: vc.getApproachPoint(obj, 100, o)})
+	}
+
+	workers := make(chan struct{}, vc.conf.cpuThreads())
+	var wg sync.WaitGroup
+	for _, ac := range candidates {
+		wg.Add(1)
+		go func(ac *approachCandidate) {
+			defer wg.Done()
+			workers <- struct{}{}
+			defer func() { <-workers }()
+			vc.planApproach(ctx, tag, fs, worldState, start, ac)
+		}(ac)
+	}
+	wg.Wait()
+
+	return candidates, ctx.Err()
+}
+
+func (vc *VinoCart) planApproach(ctx context.Context, tag string, fs *referenceframe.FrameSystem, worldState *referenceframe.WorldState, start referenceframe.FrameSystemInputs, ac *approachCandidate) {
+	ctx, cancel := context.WithTimeout(ctx, vc.conf.approachTimeout())
+	defer cancel()
+
+	plan, err := vc.planMotion(ctx, tag, &armplanning.PlanRequest{
+		FrameSystem: fs,
+		Goals: []*armplanning.PlanState{
+			armplanning.NewPlanState(referenceframe.FrameSystemPoses{vc.conf.GripperName: ac.pose}, nil),
+		},
+		StartState: armplanning.NewPlanState(nil, start),
+		WorldState: worldState,
+	})
+	if err != nil {
+		ac.err = err
+		return
+	}
+
+	ac.trajectory = planTrajectory(plan, vc.conf.ArmName)
+	for i := 1; i < len(ac.trajectory); i++ {
+		ac.travel += referenceframe.InputsL2Distance(ac.trajectory[i-1], ac.trajectory[i])
+	}
+
+	ac.clearance, err = planClearance(fs, worldState, plan.Trajectory(), []string{vc.conf.ArmName, vc.conf.GripperName})
+	if err != nil {
+		ac.err = fmt.Errorf("can't check clearance: %w", err)
+	}
+}
+
+// planClearance is the closest frames get to an obstacle in worldState over the trajectory.
+func planClearance(fs *referenceframe.FrameSystem, worldState *referenceframe.WorldState, traj motionplan.Trajectory, frames []string) (float64, error) {
+	clearance := math.Inf(1)
+	for _, inputs := range traj {
+		obstacles, err := worldState.ObstaclesInWorldFrame(fs, inputs)
+		if err != nil {
+			return 0, err
+		}
+		if len(obstacles.Geometries()) == 0 {
+			return clearance, nil
+		}
+
+		for _, name := range frames {
+			f := fs.Frame(name)
+			if f == nil {
+				return 0, fmt.Errorf("no frame %s", name)
+			}
+			gif, err := f.Geometries(inputs[name])
+			if err != nil {
+				return 0, err
+			}
+			tf, err := fs.Transform(inputs, gif, referenceframe.World)
+			if err != nil {
+				return 0, err
+			}
+			inWorld, ok := tf.(*referenceframe.GeometriesInFrame)
+			if !ok {
+				return 0, fmt.Errorf("transform of %s gave a %T", name, tf)
+			}
+
+			for _, g := range inWorld.Geometries() {
+				for _, o := range obstacles.Geometries() {
+					d, err := g.DistanceFrom(o)
+					if err != nil {
+						return 0, err
+					}
+					clearance = math.Min(clearance, d)
+				}
+			}
+		}
+	}
+	return clearance, nil
+}
+
+// bestApproach is the lowest scoring candidate that planned, or an error with
+// why each was rejected.
+func bestApproach(candidates []*approachCandidate) (*approachCandidate, error) {
+	var best *approachCandidate
+	var errs error
+	for _, ac := range candidates {
+		if ac.err != nil {
+			errs = multierr.Append(errs, fmt.Errorf("%v: %w", ac.o, ac.err))
+			continue
+		}
+		if best == nil || ac.score() < best.score() {
+			best = ac
+		}
+	}
+	if best == nil {
+		return nil, fmt.Errorf("no approach to the cup: %w", errs)
+	}
+	return best, nil
+}
@@ -0,0 +1,32 @@
+package pour
+
+import (
+	"errors"
+	"testing"
+
+	"go.viam.com/test"
+
+	"go.viam.com/rdk/spatialmath"
+)
+
+func TestBestApproach(t *testing.T) {
+	x := &spatialmath.OrientationVectorDegrees{OX: 1, Theta: 180}
+	y := &spatialmath.OrientationVectorDegrees{OY: 1, Theta: 180}
+	z := &spatialmath.OrientationVectorDegrees{OZ: -1}
+
+	far := &approachCandidate{o: x, travel: 2, clearance: 100}
+	tight := &approachCandidate{o: y, travel: 1, clearance: 10}
+	failed := &approachCandidate{o: z, err: errors.New("no path")}
+
+	test.That(t, far.score(), test.ShouldEqual, 2)
+	test.That(t, tight.score(), test.ShouldEqual, 5)
+
+	best, err := bestApproach([]*approachCandidate{tight, failed, far})
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, best, test.ShouldEqual, far)
+
+	_, err = bestApproach([]*approachCandidate{failed})
+	test.That(t, err, test.ShouldNotBeNil)
+	test.That(t, err.Error(), test.ShouldContainSubstring, "no approach to the cup")
+	test.That(t, err.Error(), test.ShouldContainSubstring, "no path")
+}
@@ -0,0 +1,95 @@
+package pour
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/golang/geo/r3"
+
+	"go.viam.com/rdk/spatialmath"
+)
+
+func vectorMap(v r3.Vector) map[string]interface{} {
+	return map[string]interface{}{"x": v.X, "y": v.Y, "z": v.Z}
+}
+
+func parseVector(v interface{}) (r3.Vector, error) {
+	m, ok := v.(map[string]interface{})
+	if !ok {
+		return r3.Vector{}, fmt.Errorf("need a map with x, y and z, not %T", v)
+	}
+	res := r3.Vector{}
+	for _, x := range []struct {
+		key string
+		f   *float64
+	}{{"x", &res.X}, {"y", &res.Y}, {"z", &res.Z}} {
+		f, ok := m[x.key].(float64)
+		if !ok {
+			return res, fmt.Errorf("%s must be a number", x.key)
+		}
+		*x.f = f
+	}
+	return res, nil
+}
+
+// Calibrate measures where bottle-top or cup-top really is on its gripper.
+//
+// Jog the marked tip (the bottle top, or the top of a held cup) to a point you
+// know in world and pass it as "reference", or for cup-top set "use_cup_finder"
+// to use the top of the one cup the cup finder sees while the gripper holds it on
+// the table. Nothing is changed, the offset is returned to be saved into config.
+func (vc *VinoCart) Calibrate(ctx context.Context, cmd map[string]interface{}) (map[string]interface{}, error) {
+	frame, _ := cmd["frame"].(string)
+	conf := vc.currentConf()
+
+	var gripperName, configKey string
+	var current r3.Vector
+	switch frame {
+	case bottleName:
+		gripperName, configKey, current = conf.BottleGripper, "bottle_top_offset", conf.bottleTopOffset()
+	case cupTopName:
+		gripperName, configKey, current = conf.GripperName, "cup_top_offset", conf.cupTopOffset()
+	default:
+		return nil, fmt.Errorf("calibrate frame must be %q or %q, not %q", bottleName, cupTopName, frame)
+	}
+
+	var reference r3.Vector
+	if cmd["use_cup_finder"] == true {
+		if frame != cupTopName {
+			return nil, fmt.Errorf("use_cup_finder only works for %s", cupTopName)
+		}
+		objects, err := vc.FindCups(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if len(objects) != 1 {
+			return nil, fmt.Errorf("need to see exactly 1 cup to calibrate, see %d", len(objects))
+		}
+		md := objects[0].MetaData()
+		reference = r3.Vector{X: md.Center().X, Y: md.Center().Y, Z: md.MaxZ}
+	} else {
+		var err error
+		reference, err = parseVector(cmd["reference"])
+		if err != nil {
+			return nil, fmt.Errorf("bad calibrate reference: %w", err)
+		}
+	}
+
+	gripperPose, err := vc.c.Motion.GetPose(ctx, gripperName, "world", nil, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	// the reference point as seen from the gripper is the offset
+	measured := spatialmath.PoseBetween(gripperPose.Pose(), spatialmath.NewPoseFromPoint(reference)).Point()
+	vc.logger.Infof("calibrated %s: %v (was %v)", frame, measured, current)
+
+	return map[string]interface{}{
+		"frame":     frame,
+		"reference": vectorMap(reference),
+		"current":   vectorMap(current),
+		"measured":  vectorMap(measured),
+		"change":    measured.Sub(current).Norm(),
+		"config":    map[string]interface{}{configKey: vectorMap(measured)},
+	}, nil
+}
@@ -3,6 +3,8 @@ package pour
 import (
 	"fmt"
 
+	"github.com/golang/geo/r3"
+
 	"go.viam.com/rdk/components/arm"
 	"go.viam.com/rdk/components/camera"
 	"go.viam.com/rdk/components/gripper"
@@ -71,12 +73,71 @@ type Config struct {
 	// optional offset for gripper height when grabbing/placing cup
 	CupGripHeightOffset float64 `json:"cup_grip_height_offset"`
 
+	// where the bottle top and cup top are in their gripper's frame, in mm,
+	// the calibrate command measures these
+	BottleTopOffset *r3.Vector `json:"bottle_top_offset,omitempty"`
+	CupTopOffset    *r3.Vector `json:"cup_top_offset,omitempty"`
+	// how far past the cup center the gripper goes to grab it, in mm, defaults to -35
+	GripperToCupCenter *float64 `json:"gripper_to_cup_center,omitempty"`
+
 	PickQualityService   string `json:"pick_quality_service"`
 	PourGlassFindService string `json:"pour_glass_find_service"`
 	GlassFullnessService string `json:"glass_fullness_service"`
 
 	Loop                    bool `json:"loop"`
 	UseGlassFullnessMLModel bool `json:"use_glass_fullness_model"`
+
+	// what loop mode does after a failed cycle: "reset" (default) or "resume"
+	LoopRecovery string `json:"loop_recovery"`
+
+	// serve every cup found instead of failing when there is more than one
+	MultiCup bool `json:"multi_cup"`
+	// order to serve cups in: "distance" (default), "x" or "y"
+	CupOrder string `json:"cup_order"`
+
+	// how to pour, unset fields use the defaults, each pour command can override it
+	PourProfile *PourProfile `json:"pour_profile,omitempty"`
+
+	// speeds by stage/step ("pour_prep/prep-grab") or named motion ("touch-pickup",
+	// "pour-tilt", "pour-return", "default"), unset uses the built in speed
+	Speeds map[string]SpeedProfile `json:"speeds,omitempty"`
+	// scales every speed and acceleration, at most 1, can be changed at runtime
+	SpeedMultiplier float64 `json:"speed_multiplier,omitempty"`
+	// how arm speeds are set: "xarm", "ur", "generic" or "auto" (default) to go by
+	// the arm's kinematics model
+	SpeedControl string `json:"speed_control,omitempty"`
+
+	// which training images are collected and where they go
+	DataCollection DataCollection `json:"data_collection"`
+
+	// where the vinoweb app is served, :9999 by default
+	Web WebConfig `json:"web"`
+
+	// named drinks, each overriding some of the above, picked with "recipe" on a
+	// command, default_recipe is used otherwise and in loop mode
+	Recipes       map[string]Recipe `json:"recipes,omitempty"`
+	DefaultRecipe string            `json:"default_recipe,omitempty"`
+
+	// where every pour cycle is recorded, defaults to the user cache dir
+	JournalPath string `json:"journal_path"`
+	// where planned pour tilts are kept between restarts, defaults to the user cache dir
+	TiltCachePath string `json:"tilt_cache_path,omitempty"`
+	// every plan is recorded here for replanning, defaults to the user cache dir
+	PlanRecordDir string `json:"plan_record_dir,omitempty"`
+	// how many plans to keep, 500 by default, -1 records none
+	PlanRecordLimit int `json:"plan_record_limit,omitempty"`
+
+	// fixed things around the cart, added to every motion request
+	Obstacles []Obstacle `json:"obstacles,omitempty"`
+
+	// most plans to run at once, defaults to half the cpus
+	CPUThreads int `json:"cpu_threads,omitempty"`
+	// longest to plan each cup approach, defaults to 20
+	ApproachTimeoutSeconds float64 `json:"approach_timeout_seconds,omitempty"`
+
+	// checks on trajectories the arms run directly, by plan tag, "default" is
+	// added to the built in limits of tags not listed
+	TrajectoryLimits map[string]TrajectoryLimits `json:"trajectory_limits,omitempty"`
 }
 
 func (cfg *Config) Validate(path string) ([]string, []string, error) {
@@ -90,9 +151,7 @@ func (cfg *Config) Validate(path string) ([]string, []string, error) {
 		deps = append(deps, cfg.PourGlassFindService)
 	}
 
-	if cfg.GlassFullnessService != "" {
-		deps = append(deps, cfg.GlassFullnessService)
-	}
+	deps = append(deps, cfg.fullnessServiceNames()...)
 
 	if cfg.ArmName == "" {
 		return nil, nil, fmt.Errorf("need an arm name")
@@ -132,11 +191,68 @@ func (cfg *Config) Validate(path string) ([]string, []string, error) {
 	for _, ps := range cfg.Positions {
 		deps = append(deps, ps.All()...)
 	}
+	if err := cfg.validatePositions(); err != nil {
+		return nil, nil, err
+	}
+	if err := cfg.validateSpeeds(); err != nil {
+		return nil, nil, err
+	}
+	if err := cfg.validateRecipes(); err != nil {
+		return nil, nil, err
+	}
 
 	if cfg.GlassPourCam != "" {
 		deps = append(deps, cfg.GlassPourCam)
 	}
 
+	if err := cfg.Web.Validate("web"); err != nil {
+		return nil, nil, err
+	}
+
+	if err := cfg.DataCollection.Validate("data_collection"); err != nil {
+		return nil, nil, err
+	}
+
+	if _, err := cfg.obstacleGeometries(); err != nil {
+		return nil, nil, err
+	}
+
+	for tag, tl := range cfg.TrajectoryLimits {
+		err := tl.Validate("trajectory_limits." + tag)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	if cfg.PlanRecordLimit < -1 {
+		return nil, nil, fmt.Errorf("plan_record_limit must be -1 to record none, or more")
+	}
+
+	if cfg.CPUThreads < 0 {
+		return nil, nil, fmt.Errorf("cpu_threads can't be negative")
+	}
+	if cfg.ApproachTimeoutSeconds < 0 {
+		return nil, nil, fmt.Errorf("approach_timeout_seconds can't be negative")
+	}
+
+	if cfg.PourProfile != nil {
+		if err := cfg.PourProfile.Validate("pour_profile"); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	switch cfg.CupOrder {
+	case "", cupOrderDistance, cupOrderX, cupOrderY:
+	default:
+		return nil, nil, fmt.Errorf("cup_order must be one of %q, %q or %q, not %q", cupOrderDistance, cupOrderX, cupOrderY, cfg.CupOrder)
+	}
+
+	switch cfg.LoopRecovery {
+	case "", loopRecoveryReset, loopRecoveryResume:
+	default:
+		return nil, nil, fmt.Errorf("loop_recovery must be %q or %q, not %q", loopRecoveryReset, loopRecoveryResume, cfg.LoopRecovery)
+	}
+
 	return deps, optionals, nil
 }
 
@@ -154,6 +270,20 @@ func (c *Config) glassPourMotionThreshold() float64 {
 	return 4
 }
 
+func (c *Config) loopRecovery() string {
+	if c.LoopRecovery != "" {
+		return c.LoopRecovery
+	}
+	return loopRecoveryReset
+}
+
+func (c *Config) cupOrder() string {
+	if c.CupOrder != "" {
+		return c.CupOrder
+	}
+	return cupOrderDistance
+}
+
 func (c *Config) cupGripHeightOffset() float64 {
 	if c.CupGripHeightOffset > 0 {
 		return c.CupGripHeightOffset
@@ -161,6 +291,27 @@ func (c *Config) cupGripHeightOffset() float64 {
 	return 25
 }
 
+func (c *Config) bottleTopOffset() r3.Vector {
+	if c.BottleTopOffset != nil {
+		return *c.BottleTopOffset
+	}
+	return r3.Vector{X: c.BottleHeight - 70, Y: -7, Z: 0}
+}
+
+func (c *Config) cupTopOffset() r3.Vector {
+	if c.CupTopOffset != nil {
+		return *c.CupTopOffset
+	}
+	return r3.Vector{X: c.cupGripHeightOffset(), Y: -75, Z: -15}
+}
+
+func (c *Config) gripperToCupCenter() float64 {
+	if c.GripperToCupCenter != nil {
+		return *c.GripperToCupCenter
+	}
+	return -35
+}
+
 type StagePositions map[string][][]toggleswitch.Switch
 
 type Pour1Components struct {
@@ -183,6 +334,8 @@ type Pour1Components struct {
 	PickQualityService   vision.Service
 	PourGlassFindService vision.Service
 	GlassFullnessService vision.Service
+	// every glass fullness service, including the recipes' ones
+	FullnessServices map[string]vision.Service
 }
 
 func Pour1ComponentsFromDependencies(config *Config, deps resource.Dependencies) (*Pour1Components, error) {
@@ -242,6 +395,16 @@ func Pour1ComponentsFromDependencies(config *Config, deps resource.Dependencies)
 		}
 	}
 
+	c.FullnessServices = map[string]vision.Service{}
+	for _, name := range config.fullnessServiceNames() {
+		s, err := vision.FromProvider(deps, name)
+		if err != nil {
+			return nil, err
+		}
+		c.FullnessServices[name] = s
+	}
+	c.GlassFullnessService = c.FullnessServices[config.GlassFullnessService]
+
 	if config.BottleGripper != "" {
 		c.BottleGripper, err = gripper.FromProvider(deps, config.BottleGripper)
 		if err != nil {
@@ -256,14 +419,23 @@ func Pour1ComponentsFromDependencies(config *Config, deps resource.Dependencies)
 		}
 	}
 
-	c.Positions = map[string]StagePositions{}
+	c.Positions, err = config.setupPositions(deps)
+	if err != nil {
+		return nil, err
+	}
+
+	return c, nil
+}
+
+// setupPositions is the switches for every stage in Positions.
+func (config *Config) setupPositions(deps resource.Dependencies) (map[string]StagePositions, error) {
+	positions := map[string]StagePositions{}
 	for k, v := range config.Positions {
 		ps, err := v.setup(deps)
 		if err != nil {
 			return nil, err
 		}
-		c.Positions[k] = ps
+		positions[k] = ps
 	}
-
-	return c, nil
+	return positions, nil
 }
@@ -0,0 +1,123 @@
+package pour
+
+import (
+	"context"
+	"fmt"
+	"image"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"time"
+
+	"go.viam.com/rdk/app"
+	"go.viam.com/rdk/resource"
+)
+
+// data collection streams
+const (
+	streamPickQuality = "pick_quality" // cup in the gripper, after grabbing
+	streamGlassCam    = "glass_cam"    // glass cam at the start of each pour
+	streamPourQuality = "pour_quality" // labeled pour images, from the under/good/over-pour commands
+)
+
+// where each stream went before it was configurable
+var defaultDatasetIDs = map[string]string{
+	streamPickQuality: "683f8952383a821481d9b5c9",
+	streamGlassCam:    "683d1210c83b3f3823ec70ff",
+	streamPourQuality: "69ebb260ec6ccb3ae5466448",
+}
+
+// DataStream is where one kind of training image goes.
+type DataStream struct {
+	Disabled  bool     `json:"disabled,omitempty"`
+	DatasetID string   `json:"dataset_id,omitempty"`
+	Tags      []string `json:"tags,omitempty"`
+	// fraction of images kept, 0 means all of them
+	SampleRate float64 `json:"sample_rate,omitempty"`
+}
+
+// DataCollection is what training data the cart collects. Everything is on by default.
+type DataCollection struct {
+	// save images under local_dir instead of uploading them
+	LocalOnly bool   `json:"local_only,omitempty"`
+	LocalDir  string `json:"local_dir,omitempty"`
+
+	PickQuality DataStream `json:"pick_quality"`
+	GlassCam    DataStream `json:"glass_cam"`
+	PourQuality DataStream `json:"pour_quality"`
+}
+
+func (dc *DataCollection) Validate(path string) error {
+	for _, name := range []string{streamPickQuality, streamGlassCam, streamPourQuality} {
+		s := dc.stream(name)
+		if s.SampleRate < 0 || s.SampleRate > 1 {
+			return fmt.Errorf("%s.%s.sample_rate must be between 0 and 1, not %v", path, name, s.SampleRate)
+		}
+	}
+	return nil
+}
+
+// stream is the named stream with its default dataset filled in.
+func (dc *DataCollection) stream(name string) DataStream {
+	var s DataStream
+	switch name {
+	case streamPickQuality:
+		s = dc.PickQuality
+	case streamGlassCam:
+		s = dc.GlassCam
+	case streamPourQuality:
+		s = dc.PourQuality
+	}
+	if s.DatasetID == "" {
+		s.DatasetID = defaultDatasetIDs[name]
+	}
+	return s
+}
+
+func (dc *DataCollection) localDir() string {
+	if dc.LocalDir != "" {
+		return dc.LocalDir
+	}
+	return filepath.Join(trainingDataDirName, "local")
+}
+
+// wanted is if this stream keeps the next image.
+func (s DataStream) wanted() bool {
+	if s.Disabled {
+		return false
+	}
+	if s.SampleRate > 0 && s.SampleRate < 1 {
+		return rand.Float64() < s.SampleRate
+	}
+	return true
+}
+
+// saveTrainingImage sends img to the named stream, if it wants it. Without an app
+// connection nothing is uploaded, local only mode saves it under local_dir instead.
+func saveTrainingImage(ctx context.Context, dc DataCollection, name string, component resource.Name, img image.Image, dataClient *app.DataClient) error {
+	s := dc.stream(name)
+	if !s.wanted() {
+		return nil
+	}
+
+	if dc.LocalOnly {
+		data, err := encodePNG(img)
+		if err != nil {
+			return err
+		}
+		fn := fmt.Sprintf("%s-%s.png", component.ShortName(), time.Now().Format("20060102_150405.000"))
+		return writeLocal(filepath.Join(dc.localDir(), name), fn, data)
+	}
+
+	if dataClient == nil {
+		return nil
+	}
+	return saveImageToDataset(ctx, component, img, dataClient, s.DatasetID, s.Tags)
+}
+
+func writeLocal(dir, fn string, data []byte) error {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(dir, fn), data, 0o644)
+}
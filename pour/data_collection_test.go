@@ -0,0 +1,52 @@
+package pour
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"go.viam.com/rdk/logging"
+	"go.viam.com/test"
+)
+
+func TestLabelPourLocalOnly(t *testing.T) {
+	ctx := context.Background()
+	t.Chdir(t.TempDir())
+	pi := &pourInsepctor{logger: logging.NewTestLogger(t)}
+
+	fakePour := func() time.Time {
+		pour := time.Now()
+		dir := dirnameForPour(pour)
+		test.That(t, os.MkdirAll(dir, 0o755), test.ShouldBeNil)
+		for i := 0; i < 8; i++ {
+			test.That(t, os.WriteFile(filepath.Join(dir, fmt.Sprintf("img-%02d.png", i)), []byte("x"), 0o644), test.ShouldBeNil)
+		}
+		return pour
+	}
+	count := func(label TrainingLabel) int {
+		files, err := findFiles(filepath.Join("out", streamPourQuality, string(label)))
+		if os.IsNotExist(err) {
+			return 0
+		}
+		test.That(t, err, test.ShouldBeNil)
+		return len(files)
+	}
+
+	dc := DataCollection{LocalOnly: true, LocalDir: "out", PourQuality: DataStream{Disabled: true}}
+	pour := fakePour()
+	test.That(t, pi.labelPour(ctx, pour, overPour, dc), test.ShouldBeNil)
+	test.That(t, count(overPour), test.ShouldEqual, 0)
+
+	dc.PourQuality.Disabled = false
+	pour = fakePour()
+	test.That(t, pi.labelPour(ctx, pour, overPour, dc), test.ShouldBeNil)
+	test.That(t, count(underPour), test.ShouldEqual, 2)
+	test.That(t, count(goodPour), test.ShouldEqual, 2)
+	test.That(t, count(overPour), test.ShouldEqual, 2)
+
+	_, err := os.Stat(dirnameForPour(pour))
+	test.That(t, os.IsNotExist(err), test.ShouldBeTrue)
+}
@@ -0,0 +1,325 @@
+package pour
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"go.viam.com/rdk/components/arm"
+	toggleswitch "go.viam.com/rdk/components/switch"
+	"go.viam.com/rdk/motionplan"
+	"go.viam.com/rdk/motionplan/armplanning"
+	"go.viam.com/rdk/referenceframe"
+	"go.viam.com/rdk/spatialmath"
+
+	"github.com/erh/vmodutils/touch"
+)
+
+// dryRunStep is one plan made by DryRun.
+type dryRunStep struct {
+	name        string
+	frame       string // the arm whose joints are reported
+	orientation *spatialmath.OrientationVectorDegrees
+	trajectory  [][]referenceframe.Input
+	best        bool // the approach picked out of all of them
+	err         error
+}
+
+func (s *dryRunStep) end() []referenceframe.Input {
+	if len(s.trajectory) == 0 {
+		return nil
+	}
+	return s.trajectory[len(s.trajectory)-1]
+}
+
+func (s *dryRunStep) toMap() map[string]interface{} {
+	m := map[string]interface{}{
+		"step": s.name,
+		"arm":  s.frame,
+		"ok":   s.err == nil,
+	}
+	if s.orientation != nil {
+		m["orientation"] = map[string]interface{}{
+			"ox":    s.orientation.OX,
+			"oy":    s.orientation.OY,
+			"oz":    s.orientation.OZ,
+			"theta": s.orientation.Theta,
+		}
+	}
+	if s.best {
+		m["best"] = true
+	}
+	if s.err != nil {
+		m["error"] = s.err.Error()
+	}
+	if len(s.trajectory) > 0 {
+		traj := []interface{}{}
+		for _, joints := range s.trajectory {
+			j := []interface{}{}
+			for _, v := range joints {
+				j = append(j, float64(v))
+			}
+			traj = append(traj, j)
+		}
+		m["trajectory"] = traj
+		m["l2"] = referenceframe.InputsL2Distance(s.trajectory[0], s.end())
+	}
+	return m
+}
+
+func planTrajectory(plan motionplan.Plan, frame string) [][]referenceframe.Input {
+	traj := [][]referenceframe.Input{}
+	for _, step := range plan.Trajectory() {
+		traj = append(traj, step[frame])
+	}
+	return traj
+}
+
+// DryRun plans the whole cycle for the cups where they are now without moving
+// anything. Each step starts where the one before it would have left the arms,
+// and the first from the touch/prep position.
+func (vc *VinoCart) DryRun(ctx context.Context) (map[string]interface{}, error) {
+	return vc.dryRun(ctx, vc.currentConf().pourProfile())
+}
+
+func (vc *VinoCart) dryRun(ctx context.Context, profile PourProfile) (map[string]interface{}, error) {
+	// a new config can't be applied halfway through planning
+	vc.confLock.RLock()
+	defer vc.confLock.RUnlock()
+
+	steps := []*dryRunStep{}
+	res := func(cupCount int) map[string]interface{} {
+		all := []interface{}{}
+		for _, s := range steps {
+			all = append(all, s.toMap())
+		}
+		// ok once the last step plans, failed approach choices are fine
+		ok := len(steps) > 0 && steps[len(steps)-1].name == "setup_pour_positions" && steps[len(steps)-1].err == nil
+		return map[string]interface{}{
+			"ok":        ok,
+			"cup_count": cupCount,
+			"steps":     all,
+		}
+	}
+
+	touchPrep, err := vc.getPositions("touch", "prep")
+	if err != nil {
+		return nil, err
+	}
+	start, err := vc.positionJoints(ctx, touchPrep)
+	if err != nil {
+		return nil, err
+	}
+
+	objects, err := vc.FindCups(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if len(objects) == 0 {
+		return nil, noObjects
+	}
+
+	gripperPose, err := vc.c.Motion.GetPose(ctx, vc.conf.GripperName, "world", nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	queue := orderCups(objects, gripperPose.Pose().Point(), vc.conf.cupOrder())
+	obj, others := queue[0], queue[1:]
+
+	// -- approach, every choice so we know which ones work, going on with the one Touch would pick
+
+	candidates, err := vc.planApproaches(ctx, "dry-run-approach", start, obj, others, approachChoices)
+	if err != nil {
+		return nil, err
+	}
+	best, err := bestApproach(candidates)
+	var approach *dryRunStep
+	for _, ac := range candidates {
+		s := &dryRunStep{name: "approach", frame: vc.conf.ArmName, orientation: ac.o, trajectory: ac.trajectory, err: ac.err}
+		if ac == best {
+			s.best = true
+			approach = s
+		}
+		steps = append(steps, s)
+	}
+	if err != nil {
+		return res(len(queue)), nil
+	}
+
+	cupFs, err := touch.FrameSystemWithSomeParts(ctx, vc.c.Rfs, []string{vc.conf.ArmName, vc.conf.GripperName}, nil)
+	if err != nil {
+		return nil, err
+	}
+	cupFsWorld, err := vc.planWorldState(ctx, cupFs, otherCupObstacles(others)...)
+	if err != nil {
+		return nil, err
+	}
+
+	// -- linear pickup
+
+	pickup := vc.dryRunPlan(ctx, "pickup", vc.conf.ArmName, &armplanning.PlanRequest{
+		FrameSystem: cupFs,
+		Goals: []*armplanning.PlanState{
+			armplanning.NewPlanState(referenceframe.FrameSystemPoses{vc.conf.GripperName: vc.getApproachPoint(obj, vc.conf.gripperToCupCenter(), approach.orientation)}, nil),
+		},
+		StartState:  armplanning.NewPlanState(nil, referenceframe.FrameSystemInputs{vc.conf.ArmName: approach.end()}),
+		WorldState:  cupFsWorld,
+		Constraints: &LinearConstraint,
+	})
+	pickup.orientation = approach.orientation
+	steps = append(steps, pickup)
+	if pickup.err != nil {
+		return res(len(queue)), nil
+	}
+
+	// -- bottle alignment, from the pour/prep position of both arms
+
+	pourPositions, err := vc.getPositions("pour", "prep")
+	if err != nil {
+		return nil, err
+	}
+	if len(pourPositions) == 0 {
+		return nil, fmt.Errorf("no positions for pour prep")
+	}
+	// Pour only goes to the first of these
+	pourPrep, err := vc.positionJoints(ctx, pourPositions[:1])
+	if err != nil {
+		return nil, err
+	}
+
+	bothFs, err := touch.FrameSystemWithSomeParts(ctx, vc.c.Rfs,
+		[]string{vc.conf.ArmName, vc.conf.GripperName, vc.conf.BottleArm, vc.conf.BottleGripper},
+		[]*referenceframe.LinkInFrame{vc.cupTop, vc.bottleTop})
+	if err != nil {
+		return nil, err
+	}
+	cupTop, err := framePose(bothFs, pourPrep, cupTopName)
+	if err != nil {
+		return nil, err
+	}
+	bottleTop, err := framePose(bothFs, pourPrep, bottleName)
+	if err != nil {
+		return nil, err
+	}
+	alignTarget := bottleAlignTarget(cupTop, bottleTop, profile.bottleGap())
+
+	alignFs, err := touch.FrameSystemWithSomeParts(ctx, vc.c.Rfs, []string{vc.conf.BottleArm, vc.conf.BottleGripper}, vc.pourExtraFrames)
+	if err != nil {
+		return nil, err
+	}
+	alignWorld, err := vc.planWorldState(ctx, alignFs)
+	if err != nil {
+		return nil, err
+	}
+	align := vc.dryRunPlan(ctx, "bottle_align", vc.conf.BottleArm, &armplanning.PlanRequest{
+		FrameSystem: alignFs,
+		Goals: []*armplanning.PlanState{
+			armplanning.NewPlanState(referenceframe.FrameSystemPoses{bottleName: alignTarget}, nil),
+		},
+		StartState: armplanning.NewPlanState(nil, referenceframe.FrameSystemInputs{vc.conf.BottleArm: pourPrep[vc.conf.BottleArm]}),
+		WorldState: alignWorld,
+	})
+	if align.err == nil {
+		if l2 := referenceframe.InputsL2Distance(align.trajectory[0], align.end()); l2 > profile.maxAlignL2() {
+			align.err = fmt.Errorf("pos too far: %v", l2)
+		}
+	}
+	steps = append(steps, align)
+	if align.err != nil {
+		return res(len(queue)), nil
+	}
+
+	// -- pour tilt
+
+	tilt := &dryRunStep{name: "setup_pour_positions", frame: vc.conf.BottleArm}
+	pp, err := vc.planPourPositions(ctx, "dry-run-pour-tilt", profile, alignFs, align.end(), alignTarget.Pose())
+	if err != nil {
+		tilt.err = err
+	} else {
+		tilt.trajectory = append([][]referenceframe.Input{align.end()}, pp.joints...)
+	}
+	steps = append(steps, tilt)
+
+	return res(len(queue)), nil
+}
+
+func (vc *VinoCart) dryRunPlan(ctx context.Context, name, frame string, req *armplanning.PlanRequest) *dryRunStep {
+	s := &dryRunStep{name: name, frame: frame}
+	plan, err := vc.planMotion(ctx, "dry-run-"+name, req)
+	if err != nil {
+		s.err = err
+		return s
+	}
+	s.trajectory = planTrajectory(plan, frame)
+	return s
+}
+
+// positionJoints is where the arms would be after going through positions, from
+// the switches' saved joints. Arms without saved joints use where they are now.
+func (vc *VinoCart) positionJoints(ctx context.Context, positions [][]toggleswitch.Switch) (referenceframe.FrameSystemInputs, error) {
+	inputs := referenceframe.FrameSystemInputs{}
+	for _, group := range positions {
+		for _, p := range group {
+			cfg, err := switchConfig(ctx, p)
+			if err != nil {
+				return nil, err
+			}
+
+			if len(cfg.Joints) == 0 {
+				vc.logger.Warnf("position %v has no saved joints, using current joints of %s", p.Name(), cfg.Arm)
+				continue
+			}
+
+			joints := []referenceframe.Input{}
+			for _, j := range cfg.Joints {
+				joints = append(joints, referenceframe.Input(j))
+			}
+			inputs[cfg.Arm] = joints
+		}
+	}
+
+	for name, a := range map[string]arm.Arm{vc.conf.ArmName: vc.c.Arm, vc.conf.BottleArm: vc.c.BottleArm} {
+		if _, ok := inputs[name]; ok {
+			continue
+		}
+		joints, err := a.JointPositions(ctx, nil)
+		if err != nil {
+			return nil, err
+		}
+		inputs[name] = joints
+	}
+
+	return inputs, nil
+}
+
+// switchConfig is position switch p's config, with the arm and joints it goes to.
+func switchConfig(ctx context.Context, p toggleswitch.Switch) (*touch.ArmPositionSaverConfig, error) {
+	res, err := p.DoCommand(ctx, map[string]interface{}{"cfg": true})
+	if err != nil {
+		return nil, fmt.Errorf("can't get config of position %v: %w", p.Name(), err)
+	}
+
+	asJSON, ok := res["as_json"].(string)
+	if !ok {
+		return nil, fmt.Errorf("position %v config has no as_json", p.Name())
+	}
+
+	cfg := &touch.ArmPositionSaverConfig{}
+	err = json.Unmarshal([]byte(asJSON), cfg)
+	if err != nil {
+		return nil, fmt.Errorf("bad config from position %v: %w", p.Name(), err)
+	}
+	return cfg, nil
+}
+
+func framePose(fs *referenceframe.FrameSystem, inputs referenceframe.FrameSystemInputs, name string) (spatialmath.Pose, error) {
+	tf, err := fs.Transform(inputs, referenceframe.NewPoseInFrame(name, spatialmath.NewZeroPose()), referenceframe.World)
+	if err != nil {
+		return nil, err
+	}
+	pif, ok := tf.(*referenceframe.PoseInFrame)
+	if !ok {
+		return nil, fmt.Errorf("transform of %s gave a %T", name, tf)
+	}
+	return pif.Pose(), nil
+}
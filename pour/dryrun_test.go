@@ -0,0 +1,45 @@
+package pour
+
+import (
+	"context"
+	"testing"
+
+	"go.viam.com/test"
+)
+
+func TestSimDryRun(t *testing.T) {
+	ctx := context.Background()
+	vc, cart := newSimVinoCart(t)
+	putCup(t, cart, 450, 0)
+
+	before, err := cart.Arm.JointPositions(ctx, nil)
+	test.That(t, err, test.ShouldBeNil)
+
+	res, err := vc.DryRun(ctx)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, res["ok"], test.ShouldBeTrue)
+
+	var best, pickup map[string]interface{}
+	approaches := 0
+	for _, s := range res["steps"].([]interface{}) {
+		m := s.(map[string]interface{})
+		switch m["step"] {
+		case "approach":
+			approaches++
+			if m["best"] == true {
+				test.That(t, best, test.ShouldBeNil)
+				best = m
+			}
+		case "pickup":
+			pickup = m
+		}
+	}
+	test.That(t, approaches, test.ShouldEqual, len(approachChoices))
+	test.That(t, best, test.ShouldNotBeNil)
+	// picks up from the approach Touch would take
+	test.That(t, pickup["orientation"], test.ShouldResemble, best["orientation"])
+
+	after, err := cart.Arm.JointPositions(ctx, nil)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, after, test.ShouldResemble, before)
+}
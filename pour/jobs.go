@@ -0,0 +1,229 @@
+package pour
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+type jobState string
+
+const (
+	jobRunning   = jobState("running")
+	jobSucceeded = jobState("succeeded")
+	jobFailed    = jobState("failed")
+	jobCanceled  = jobState("canceled")
+)
+
+// how many finished jobs we remember for job_status / list_jobs
+const maxFinishedJobs = 20
+
+// job is a VinoCart command running in the background.
+type job struct {
+	id      string
+	command string
+
+	started  time.Time
+	finished time.Time
+	state    jobState
+	err      error
+
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// jobManager runs motion commands in the background, one at a time, since
+// two commands moving the same arms at once is never what anyone wants.
+type jobManager struct {
+	progress func() CycleState
+
+	lock    sync.Mutex
+	nextID  int
+	jobs    map[string]*job
+	order   []string
+	running *job
+	wg      sync.WaitGroup
+}
+
+func newJobManager(progress func() CycleState) *jobManager {
+	return &jobManager{
+		progress: progress,
+		jobs:     map[string]*job{},
+	}
+}
+
+// start runs f in a new goroutine with its own cancelable context.
+func (jm *jobManager) start(command string, f func(ctx context.Context) error) (*job, error) {
+	jm.lock.Lock()
+	defer jm.lock.Unlock()
+
+	if jm.running != nil {
+		return nil, fmt.Errorf("job %s (%s) is already running", jm.running.id, jm.running.command)
+	}
+
+	jm.nextID++
+	ctx, cancel := context.WithCancel(context.Background())
+	j := &job{
+		id:      fmt.Sprintf("job-%d", jm.nextID),
+		command: command,
+		started: time.Now(),
+		state:   jobRunning,
+		cancel:  cancel,
+		done:    make(chan struct{}),
+	}
+
+	jm.jobs[j.id] = j
+	jm.order = append(jm.order, j.id)
+	jm.running = j
+	jm.pruneLocked()
+
+	jm.wg.Add(1)
+	go func() {
+		defer jm.wg.Done()
+		defer cancel()
+		err := f(ctx)
+		jm.finish(ctx, j, err)
+	}()
+
+	return j, nil
+}
+
+func (jm *jobManager) finish(ctx context.Context, j *job, err error) {
+	jm.lock.Lock()
+	defer jm.lock.Unlock()
+
+	j.finished = time.Now()
+	j.err = err
+	switch {
+	case err == nil:
+		j.state = jobSucceeded
+	case ctx.Err() != nil && errors.Is(err, context.Canceled):
+		j.state = jobCanceled
+	default:
+		j.state = jobFailed
+	}
+
+	if jm.running == j {
+		jm.running = nil
+	}
+	close(j.done)
+}
+
+// wait blocks until the job is done, canceling it if ctx goes away first.
+func (jm *jobManager) wait(ctx context.Context, j *job) error {
+	select {
+	case <-j.done:
+	case <-ctx.Done():
+		j.cancel()
+		<-j.done
+	}
+
+	jm.lock.Lock()
+	defer jm.lock.Unlock()
+	return j.err
+}
+
+func (jm *jobManager) cancelJob(id string) error {
+	jm.lock.Lock()
+	j, ok := jm.jobs[id]
+	jm.lock.Unlock()
+
+	if !ok {
+		return fmt.Errorf("no job %s", id)
+	}
+	j.cancel()
+	return nil
+}
+
+// cancelAll cancels every job and waits for them to return.
+func (jm *jobManager) cancelAll() {
+	jm.lock.Lock()
+	for _, j := range jm.jobs {
+		j.cancel()
+	}
+	jm.lock.Unlock()
+
+	jm.wg.Wait()
+}
+
+func (jm *jobManager) status(id string) (map[string]interface{}, error) {
+	jm.lock.Lock()
+	defer jm.lock.Unlock()
+
+	j, ok := jm.jobs[id]
+	if !ok {
+		return nil, fmt.Errorf("no job %s", id)
+	}
+	return jm.jobToMapLocked(j), nil
+}
+
+func (jm *jobManager) list() []interface{} {
+	jm.lock.Lock()
+	defer jm.lock.Unlock()
+
+	res := []interface{}{}
+	for _, id := range jm.order {
+		res = append(res, jm.jobToMapLocked(jm.jobs[id]))
+	}
+	return res
+}
+
+func (jm *jobManager) jobToMapLocked(j *job) map[string]interface{} {
+	m := map[string]interface{}{
+		"job_id":  j.id,
+		"command": j.command,
+		"state":   string(j.state),
+		"started": j.started.Format(time.RFC3339Nano),
+	}
+
+	if j.state == jobRunning {
+		m["running_ms"] = float64(time.Since(j.started).Milliseconds())
+		if jm.progress != nil {
+			m["progress"] = string(jm.progress())
+		}
+	} else {
+		m["finished"] = j.finished.Format(time.RFC3339Nano)
+		m["duration_ms"] = float64(j.finished.Sub(j.started).Milliseconds())
+	}
+
+	if j.err != nil {
+		m["error"] = j.err.Error()
+	}
+
+	return m
+}
+
+// pruneLocked drops the oldest finished jobs past maxFinishedJobs.
+func (jm *jobManager) pruneLocked() {
+	finished := 0
+	for _, id := range jm.order {
+		if jm.jobs[id].state != jobRunning {
+			finished++
+		}
+	}
+
+	keep := []string{}
+	for _, id := range jm.order {
+		j := jm.jobs[id]
+		if finished > maxFinishedJobs && j.state != jobRunning {
+			delete(jm.jobs, id)
+			finished--
+			continue
+		}
+		keep = append(keep, id)
+	}
+	jm.order = keep
+}
+
+// busy returns an error naming the running job, if there is one.
+func (jm *jobManager) busy() error {
+	jm.lock.Lock()
+	defer jm.lock.Unlock()
+
+	if jm.running != nil {
+		return fmt.Errorf("job %s (%s) is running", jm.running.id, jm.running.command)
+	}
+	return nil
+}
@@ -0,0 +1,172 @@
+package pour
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"go.viam.com/test"
+)
+
+func TestJobManager(t *testing.T) {
+	jm := newJobManager(func() CycleState { return StatePicking })
+
+	release := make(chan struct{})
+	j, err := jm.start("touch", func(ctx context.Context) error {
+		<-release
+		return nil
+	})
+	test.That(t, err, test.ShouldBeNil)
+
+	s, err := jm.status(j.id)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, s["state"], test.ShouldEqual, string(jobRunning))
+	test.That(t, s["progress"], test.ShouldEqual, string(StatePicking))
+
+	// one at a time
+	_, err = jm.start("pour", func(ctx context.Context) error { return nil })
+	test.That(t, err, test.ShouldNotBeNil)
+	test.That(t, err.Error(), test.ShouldContainSubstring, "already running")
+	test.That(t, jm.busy(), test.ShouldNotBeNil)
+
+	close(release)
+	test.That(t, jm.wait(context.Background(), j), test.ShouldBeNil)
+	s, err = jm.status(j.id)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, s["state"], test.ShouldEqual, string(jobSucceeded))
+	test.That(t, jm.busy(), test.ShouldBeNil)
+
+	j, err = jm.start("pour", func(ctx context.Context) error { return errors.New("spilled") })
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, jm.wait(context.Background(), j), test.ShouldNotBeNil)
+	s, err = jm.status(j.id)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, s["state"], test.ShouldEqual, string(jobFailed))
+	test.That(t, s["error"], test.ShouldEqual, "spilled")
+
+	_, err = jm.status("job-100")
+	test.That(t, err, test.ShouldNotBeNil)
+	test.That(t, jm.cancelJob("job-100"), test.ShouldNotBeNil)
+}
+
+func TestJobManagerCancel(t *testing.T) {
+	jm := newJobManager(nil)
+
+	j, err := jm.start("demo", func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, jm.cancelJob(j.id), test.ShouldBeNil)
+	test.That(t, jm.wait(context.Background(), j), test.ShouldBeError, context.Canceled)
+
+	s, err := jm.status(j.id)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, s["state"], test.ShouldEqual, string(jobCanceled))
+
+	// the caller going away cancels what it's waiting for
+	j, err = jm.start("demo", func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	test.That(t, err, test.ShouldBeNil)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	test.That(t, jm.wait(ctx, j), test.ShouldBeError, context.Canceled)
+
+	jm.cancelAll()
+}
+
+func TestJobManagerPrune(t *testing.T) {
+	jm := newJobManager(nil)
+	for i := 0; i < maxFinishedJobs+5; i++ {
+		j, err := jm.start(fmt.Sprintf("cmd-%d", i), func(ctx context.Context) error { return nil })
+		test.That(t, err, test.ShouldBeNil)
+		test.That(t, jm.wait(context.Background(), j), test.ShouldBeNil)
+	}
+	// pruned when the next one starts
+	j, err := jm.start("last", func(ctx context.Context) error { return nil })
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, jm.wait(context.Background(), j), test.ShouldBeNil)
+
+	jobs := jm.list()
+	test.That(t, len(jobs), test.ShouldEqual, maxFinishedJobs+1)
+	test.That(t, jobs[len(jobs)-1].(map[string]interface{})["command"], test.ShouldEqual, "last")
+	_, err = jm.status("job-1")
+	test.That(t, err, test.ShouldNotBeNil)
+}
+
+func TestSimMotionCommandJobs(t *testing.T) {
+	ctx := context.Background()
+	vc, _ := newSimVinoCart(t)
+
+	// async by default, the job id comes back before it's done
+	res, err := vc.DoCommand(ctx, map[string]interface{}{"reset": true})
+	test.That(t, err, test.ShouldBeNil)
+	id, ok := res["job_id"].(string)
+	test.That(t, ok, test.ShouldBeTrue)
+	vc.jobs.lock.Lock()
+	j := vc.jobs.jobs[id]
+	vc.jobs.lock.Unlock()
+	test.That(t, vc.jobs.wait(ctx, j), test.ShouldBeNil)
+
+	status, err := vc.DoCommand(ctx, map[string]interface{}{"job_status": id})
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, status["command"], test.ShouldEqual, "reset")
+	test.That(t, status["state"], test.ShouldEqual, string(jobSucceeded))
+
+	// blocking is done when it returns
+	res, err = vc.DoCommand(ctx, map[string]interface{}{"reset": true, "blocking": true})
+	test.That(t, err, test.ShouldBeNil)
+	status, err = vc.DoCommand(ctx, map[string]interface{}{"job_status": res["job_id"]})
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, status["state"], test.ShouldEqual, string(jobSucceeded))
+
+	// and says why it failed, with no cups to touch
+	_, err = vc.DoCommand(ctx, map[string]interface{}{"touch": true, "blocking": true})
+	test.That(t, err, test.ShouldBeError, noObjects)
+
+	// nothing else moves while a job is running
+	busy, err := vc.jobs.start("demo", func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	test.That(t, err, test.ShouldBeNil)
+	_, err = vc.DoCommand(ctx, map[string]interface{}{"reset": true})
+	test.That(t, err, test.ShouldNotBeNil)
+	test.That(t, err.Error(), test.ShouldContainSubstring, busy.id)
+
+	_, err = vc.DoCommand(ctx, map[string]interface{}{"cancel_job": busy.id})
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, vc.jobs.wait(ctx, busy), test.ShouldBeError, context.Canceled)
+	status, err = vc.DoCommand(ctx, map[string]interface{}{"job_status": busy.id})
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, status["state"], test.ShouldEqual, string(jobCanceled))
+}
+
+func TestSimAbortBeforeJobTracked(t *testing.T) {
+	ctx := context.Background()
+	vc, _ := newSimVinoCart(t)
+
+	// hold the job up before its context is tracked
+	vc.ops.lock.Lock()
+	ran := false
+	res, err := vc.runMotionCommand(ctx, "touch", "", func(ctx context.Context) error {
+		ran = true
+		return nil
+	}, false)
+	test.That(t, err, test.ShouldBeNil)
+
+	// what Abort does, with nothing tracked to cancel yet
+	vc.state.latchFault("stop button", "")
+	vc.ops.lock.Unlock()
+	vc.ops.cancelAll()
+
+	vc.jobs.lock.Lock()
+	j := vc.jobs.jobs[res["job_id"].(string)]
+	vc.jobs.lock.Unlock()
+	test.That(t, vc.jobs.wait(ctx, j), test.ShouldEqual, errFaulted)
+	test.That(t, ran, test.ShouldBeFalse)
+	test.That(t, vc.getStatus(), test.ShouldEqual, StateFaulted)
+}
@@ -0,0 +1,338 @@
+package pour
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"go.viam.com/rdk/spatialmath"
+)
+
+// defaultJournalPath is where each pour cycle is appended as one JSON line.
+var defaultJournalPath = func() string {
+	cacheDir, err := os.UserCacheDir()
+	if err != nil {
+		return "/tmp/viam-pour-journal.jsonl"
+	}
+
+	return filepath.Join(cacheDir, "viam-pour-journal.jsonl")
+}()
+
+const (
+	outcomeSuccess  = "success"
+	outcomeFailed   = "failed"
+	outcomeCanceled = "canceled"
+)
+
+const (
+	stopReasonMotion   = "motion_delta"
+	stopReasonMLModel  = "ml_model"
+	stopReasonTimeout  = "timeout"
+	stopReasonCanceled = "canceled"
+)
+
+// cycleRecord is everything we keep about one FullDemo or loop cycle.
+type cycleRecord struct {
+	ID      string    `json:"id"`
+	Command string    `json:"command"`
+	Start   time.Time `json:"start"`
+	End     time.Time `json:"end"`
+
+	DurationMs float64            `json:"duration_ms"`
+	StageMs    map[string]float64 `json:"stage_ms"`
+
+	CupCount            int                                   `json:"cup_count,omitempty"`
+	ApproachOrientation *spatialmath.OrientationVectorDegrees `json:"approach_orientation,omitempty"`
+	AlignTries          int                                   `json:"align_tries,omitempty"`
+	PourDeltas          []float64                             `json:"pour_deltas,omitempty"`
+	StopReason          string                                `json:"stop_reason,omitempty"`
+
+	Outcome     string `json:"outcome"`
+	FailedStage string `json:"failed_stage,omitempty"`
+	Error       string `json:"error,omitempty"`
+
+	// last stage started, which is the one that failed if the cycle fails
+	currentStage string
+}
+
+func (r *cycleRecord) toMap() (map[string]interface{}, error) {
+	data, err := json.Marshal(r)
+	if err != nil {
+		return nil, err
+	}
+	m := map[string]interface{}{}
+	return m, json.Unmarshal(data, &m)
+}
+
+// journal is an append-only JSONL file of cycleRecords.
+type journal struct {
+	path string
+	lock sync.Mutex
+}
+
+func newJournal(path string) *journal {
+	if path == "" {
+		path = defaultJournalPath
+	}
+	return &journal{path: path}
+}
+
+func (j *journal) setPath(path string) {
+	if path == "" {
+		path = defaultJournalPath
+	}
+	j.lock.Lock()
+	defer j.lock.Unlock()
+	j.path = path
+}
+
+func (j *journal) getPath() string {
+	j.lock.Lock()
+	defer j.lock.Unlock()
+	return j.path
+}
+
+func (j *journal) append(r *cycleRecord) error {
+	data, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+
+	j.lock.Lock()
+	defer j.lock.Unlock()
+
+	err = os.MkdirAll(filepath.Dir(j.path), 0o755)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(j.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	_, err = f.Write(append(data, '\n'))
+	return err
+}
+
+type historyQuery struct {
+	Limit      int
+	Offset     int
+	Outcome    string
+	Command    string
+	StopReason string
+	Since      time.Time
+	Until      time.Time
+}
+
+func parseHistoryQuery(cmd map[string]interface{}) (historyQuery, error) {
+	q := historyQuery{Limit: 20}
+
+	if v, ok := cmd["limit"].(float64); ok {
+		q.Limit = int(v)
+	}
+	if v, ok := cmd["offset"].(float64); ok {
+		q.Offset = int(v)
+	}
+	if v, ok := cmd["outcome"].(string); ok {
+		q.Outcome = v
+	}
+	if v, ok := cmd["command"].(string); ok {
+		q.Command = v
+	}
+	if v, ok := cmd["stop_reason"].(string); ok {
+		q.StopReason = v
+	}
+
+	for _, x := range []struct {
+		key string
+		t   *time.Time
+	}{{"since", &q.Since}, {"until", &q.Until}} {
+		s, ok := cmd[x.key].(string)
+		if !ok {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, s)
+		if err != nil {
+			return q, fmt.Errorf("bad %s: %w", x.key, err)
+		}
+		*x.t = t
+	}
+
+	if q.Limit <= 0 || q.Offset < 0 {
+		return q, fmt.Errorf("limit must be positive and offset can't be negative")
+	}
+	return q, nil
+}
+
+func (q historyQuery) matches(r *cycleRecord) bool {
+	if q.Outcome != "" && r.Outcome != q.Outcome {
+		return false
+	}
+	if q.Command != "" && r.Command != q.Command {
+		return false
+	}
+	if q.StopReason != "" && r.StopReason != q.StopReason {
+		return false
+	}
+	if !q.Since.IsZero() && r.Start.Before(q.Since) {
+		return false
+	}
+	if !q.Until.IsZero() && r.Start.After(q.Until) {
+		return false
+	}
+	return true
+}
+
+// read returns one page of matching records, newest first, and how many matched in total.
+func (j *journal) read(q historyQuery) ([]*cycleRecord, int, error) {
+	j.lock.Lock()
+	defer j.lock.Unlock()
+
+	f, err := os.Open(j.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, 0, nil
+	}
+	if err != nil {
+		return nil, 0, err
+	}
+	defer f.Close()
+
+	all := []*cycleRecord{}
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 4*1024*1024)
+	for scanner.Scan() {
+		r := &cycleRecord{}
+		if err := json.Unmarshal(scanner.Bytes(), r); err != nil {
+			// a torn last line from a crash shouldn't hide everything else
+			continue
+		}
+		if q.matches(r) {
+			all = append(all, r)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, 0, err
+	}
+
+	page := []*cycleRecord{}
+	for i := len(all) - 1 - q.Offset; i >= 0 && len(page) < q.Limit; i-- {
+		page = append(page, all[i])
+	}
+	return page, len(all), nil
+}
+
+// startCycle begins recording a cycle, returning nil if one is already being recorded.
+func (vc *VinoCart) startCycle(command string) *cycleRecord {
+	vc.cycleLock.Lock()
+	defer vc.cycleLock.Unlock()
+
+	if vc.cycle != nil {
+		return nil
+	}
+
+	now := time.Now()
+	vc.cycle = &cycleRecord{
+		ID:      now.Format("20060102_150405.000"),
+		Command: command,
+		Start:   now,
+		StageMs: map[string]float64{},
+	}
+	return vc.cycle
+}
+
+func (vc *VinoCart) endCycle(r *cycleRecord, err error) {
+	if r == nil {
+		return
+	}
+
+	vc.cycleLock.Lock()
+	vc.cycle = nil
+	vc.cycleLock.Unlock()
+
+	if err == noObjects {
+		// nobody showed up, not worth a record
+		return
+	}
+
+	r.End = time.Now()
+	r.DurationMs = float64(r.End.Sub(r.Start).Milliseconds())
+	switch {
+	case err == nil:
+		r.Outcome = outcomeSuccess
+	case errors.Is(err, context.Canceled):
+		r.Outcome = outcomeCanceled
+	default:
+		r.Outcome = outcomeFailed
+	}
+	if err != nil {
+		r.Error = err.Error()
+		r.FailedStage = r.currentStage
+	}
+
+	vc.metrics.recordCycle(r)
+
+	jerr := vc.journal.append(r)
+	if jerr != nil {
+		vc.logger.Warnf("can't write to journal %s: %v", vc.journal.getPath(), jerr)
+	}
+}
+
+// recordCycle changes the cycle being recorded, if there is one.
+func (vc *VinoCart) recordCycle(f func(r *cycleRecord)) {
+	vc.cycleLock.Lock()
+	defer vc.cycleLock.Unlock()
+	if vc.cycle != nil {
+		f(vc.cycle)
+	}
+}
+
+// timeStage adds the time until the returned func is called to the named stage.
+// Use as: defer vc.timeStage("reset")()
+func (vc *VinoCart) timeStage(name string) func() {
+	start := time.Now()
+	vc.recordCycle(func(r *cycleRecord) {
+		r.currentStage = name
+	})
+	return func() {
+		d := time.Since(start)
+		vc.recordCycle(func(r *cycleRecord) {
+			r.StageMs[name] += float64(d.Milliseconds())
+		})
+	}
+}
+
+func (vc *VinoCart) history(cmd map[string]interface{}) (map[string]interface{}, error) {
+	q, err := parseHistoryQuery(cmd)
+	if err != nil {
+		return nil, err
+	}
+
+	records, total, err := vc.journal.read(q)
+	if err != nil {
+		return nil, err
+	}
+
+	cycles := []interface{}{}
+	for _, r := range records {
+		m, err := r.toMap()
+		if err != nil {
+			return nil, err
+		}
+		cycles = append(cycles, m)
+	}
+
+	return map[string]interface{}{
+		"cycles": cycles,
+		"total":  total,
+		"offset": q.Offset,
+		"limit":  q.Limit,
+	}, nil
+}
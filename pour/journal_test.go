@@ -0,0 +1,56 @@
+package pour
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"go.viam.com/test"
+)
+
+func TestJournalHistory(t *testing.T) {
+	j := newJournal(filepath.Join(t.TempDir(), "journal.jsonl"))
+
+	records, total, err := j.read(historyQuery{Limit: 10})
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, total, test.ShouldEqual, 0)
+	test.That(t, records, test.ShouldBeEmpty)
+
+	start := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	for i := 0; i < 5; i++ {
+		outcome := outcomeSuccess
+		if i%2 == 1 {
+			outcome = outcomeFailed
+		}
+		err := j.append(&cycleRecord{
+			ID:      start.Add(time.Duration(i) * time.Minute).Format(time.RFC3339),
+			Command: "full-demo",
+			Start:   start.Add(time.Duration(i) * time.Minute),
+			StageMs: map[string]float64{"reset": float64(i)},
+			Outcome: outcome,
+		})
+		test.That(t, err, test.ShouldBeNil)
+	}
+
+	records, total, err = j.read(historyQuery{Limit: 2})
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, total, test.ShouldEqual, 5)
+	test.That(t, len(records), test.ShouldEqual, 2)
+	test.That(t, records[0].StageMs["reset"], test.ShouldEqual, 4) // newest first
+
+	records, _, err = j.read(historyQuery{Limit: 2, Offset: 4})
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, len(records), test.ShouldEqual, 1)
+	test.That(t, records[0].StageMs["reset"], test.ShouldEqual, 0)
+
+	records, total, err = j.read(historyQuery{Limit: 10, Outcome: outcomeFailed})
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, total, test.ShouldEqual, 2)
+	test.That(t, len(records), test.ShouldEqual, 2)
+
+	q, err := parseHistoryQuery(map[string]interface{}{"since": start.Add(3 * time.Minute).Format(time.RFC3339)})
+	test.That(t, err, test.ShouldBeNil)
+	_, total, err = j.read(q)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, total, test.ShouldEqual, 2)
+}
@@ -0,0 +1,214 @@
+package pour
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+type loopState string
+
+const (
+	loopStopped  = loopState("stopped")
+	loopRunning  = loopState("running")
+	loopPausing  = loopState("pausing") // finishing the current cycle before holding
+	loopPaused   = loopState("paused")
+	loopStopping = loopState("stopping") // finishing the current cycle before stopping
+)
+
+// loopControl tracks the unattended loop so it can be started, paused and
+// stopped at runtime instead of only from Config.Loop.
+type loopControl struct {
+	lock   sync.Mutex
+	state  loopState
+	since  time.Time
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+func newLoopControl() *loopControl {
+	return &loopControl{state: loopStopped, since: time.Now()}
+}
+
+func (lc *loopControl) get() loopState {
+	lc.lock.Lock()
+	defer lc.lock.Unlock()
+	return lc.state
+}
+
+func (lc *loopControl) setLocked(s loopState) {
+	lc.state = s
+	lc.since = time.Now()
+}
+
+// active is true unless the loop is stopped.
+func (lc *loopControl) active() bool {
+	return lc.get() != loopStopped
+}
+
+// running is false once the loop has been asked to pause or stop.
+func (lc *loopControl) running() bool {
+	return lc.get() == loopRunning
+}
+
+// blocksManual is true while the loop owns the arms.
+func (lc *loopControl) blocksManual() bool {
+	s := lc.get()
+	return s == loopRunning || s == loopPausing || s == loopStopping
+}
+
+func (lc *loopControl) status() map[string]interface{} {
+	lc.lock.Lock()
+	defer lc.lock.Unlock()
+	return map[string]interface{}{
+		"state": string(lc.state),
+		"since": lc.since.Format(time.RFC3339Nano),
+	}
+}
+
+// finishStopping is called by the loop between cycles. It stops the loop if
+// it was asked to stop after the current cycle, returning true if it did.
+func (lc *loopControl) finishStopping() bool {
+	lc.lock.Lock()
+	defer lc.lock.Unlock()
+	if lc.state != loopStopping {
+		return false
+	}
+	if lc.cancel != nil {
+		lc.cancel()
+		lc.cancel = nil
+	}
+	lc.setLocked(loopStopped)
+	return true
+}
+
+// holdIfPaused is called by the loop between cycles. It finishes a pause
+// and blocks until resumed, returning false if the loop is going away.
+func (lc *loopControl) holdIfPaused(ctx context.Context) bool {
+	lc.lock.Lock()
+	if lc.state == loopPausing {
+		lc.setLocked(loopPaused)
+	}
+	lc.lock.Unlock()
+
+	for ctx.Err() == nil && lc.get() == loopPaused {
+		select {
+		case <-ctx.Done():
+		case <-time.After(250 * time.Millisecond):
+		}
+	}
+	return ctx.Err() == nil
+}
+
+// StartLoop starts the unattended loop.
+func (vc *VinoCart) StartLoop() error {
+	if err := vc.jobs.busy(); err != nil {
+		return fmt.Errorf("can't start loop: %w", err)
+	}
+
+	lc := vc.loop
+	lc.lock.Lock()
+	defer lc.lock.Unlock()
+
+	if lc.state != loopStopped {
+		return fmt.Errorf("loop is already %s", lc.state)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	lc.cancel = cancel
+	lc.setLocked(loopRunning)
+	lc.wg.Add(1)
+	go func() {
+		defer lc.wg.Done()
+		vc.run(ctx)
+	}()
+
+	vc.logger.Infof("loop started")
+	return nil
+}
+
+// PauseLoop lets the current cycle finish, then holds the loop until ResumeLoop.
+func (vc *VinoCart) PauseLoop() error {
+	lc := vc.loop
+	lc.lock.Lock()
+	defer lc.lock.Unlock()
+
+	switch lc.state {
+	case loopRunning:
+		lc.setLocked(loopPausing)
+		vc.logger.Infof("loop pausing after the current cycle")
+		return nil
+	case loopPausing, loopPaused:
+		return nil
+	default:
+		return fmt.Errorf("loop is %s, can't pause", lc.state)
+	}
+}
+
+// stopLoopAfterCycle lets the current cycle finish, then stops the loop.
+func (vc *VinoCart) stopLoopAfterCycle() error {
+	lc := vc.loop
+	lc.lock.Lock()
+	defer lc.lock.Unlock()
+
+	switch lc.state {
+	case loopRunning, loopPausing, loopPaused:
+		// run stops itself between cycles, or right away if it's holding
+		lc.setLocked(loopStopping)
+		vc.logger.Infof("loop stopping after the current cycle")
+		return nil
+	default:
+		return nil
+	}
+}
+
+func (vc *VinoCart) ResumeLoop() error {
+	if err := vc.jobs.busy(); err != nil {
+		return fmt.Errorf("can't resume loop: %w", err)
+	}
+
+	lc := vc.loop
+	lc.lock.Lock()
+	defer lc.lock.Unlock()
+
+	switch lc.state {
+	case loopPausing, loopPaused, loopStopping:
+		lc.setLocked(loopRunning)
+		vc.logger.Infof("loop resumed")
+		return nil
+	case loopRunning:
+		return nil
+	default:
+		return fmt.Errorf("loop is %s, use start_loop", lc.state)
+	}
+}
+
+// StopLoop cancels the loop, including any cycle in progress, and waits for it to exit.
+func (vc *VinoCart) StopLoop() error {
+	lc := vc.loop
+	lc.lock.Lock()
+	if lc.state == loopStopped {
+		lc.lock.Unlock()
+		return nil
+	}
+	lc.cancel()
+	lc.lock.Unlock()
+
+	lc.wg.Wait()
+
+	lc.lock.Lock()
+	lc.cancel = nil
+	lc.setLocked(loopStopped)
+	lc.lock.Unlock()
+
+	vc.loopStopped()
+	return nil
+}
+
+func (vc *VinoCart) loopStopped() {
+	if vc.getStatus() != StateFaulted {
+		vc.setStatus(StateManual)
+	}
+	vc.logger.Infof("loop stopped")
+}
@@ -0,0 +1,68 @@
+package pour
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"go.viam.com/test"
+)
+
+func TestLoopControlHold(t *testing.T) {
+	lc := newLoopControl()
+	lc.setLocked(loopPausing)
+
+	go func() {
+		for lc.get() != loopPaused {
+			time.Sleep(10 * time.Millisecond)
+		}
+		lc.lock.Lock()
+		lc.setLocked(loopRunning)
+		lc.lock.Unlock()
+	}()
+
+	test.That(t, lc.holdIfPaused(context.Background()), test.ShouldBeTrue)
+	test.That(t, lc.get(), test.ShouldEqual, loopRunning)
+
+	lc.setLocked(loopPaused)
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	test.That(t, lc.holdIfPaused(ctx), test.ShouldBeFalse)
+}
+
+func TestSimPauseLoopEmptyTable(t *testing.T) {
+	vc, _ := newSimVinoCart(t)
+
+	test.That(t, vc.StartLoop(), test.ShouldBeNil)
+	test.That(t, vc.PauseLoop(), test.ShouldBeNil)
+
+	deadline := time.Now().Add(10 * time.Second)
+	for vc.loop.get() != loopPaused && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	test.That(t, vc.loop.get(), test.ShouldEqual, loopPaused)
+	test.That(t, vc.loop.blocksManual(), test.ShouldBeFalse)
+	test.That(t, vc.getStatus(), test.ShouldNotEqual, StateFaulted)
+
+	test.That(t, vc.StopLoop(), test.ShouldBeNil)
+}
+
+func TestSimStopLoopAfterCycle(t *testing.T) {
+	vc, _ := newSimVinoCart(t)
+
+	test.That(t, vc.StartLoop(), test.ShouldBeNil)
+	test.That(t, vc.stopLoopAfterCycle(), test.ShouldBeNil)
+	test.That(t, vc.loop.blocksManual(), test.ShouldBeTrue)
+
+	deadline := time.Now().Add(10 * time.Second)
+	for vc.loop.get() != loopStopped && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	test.That(t, vc.loop.get(), test.ShouldEqual, loopStopped)
+	vc.loop.wg.Wait()
+	test.That(t, vc.getStatus(), test.ShouldEqual, StateManual)
+
+	// can start again after
+	test.That(t, vc.StartLoop(), test.ShouldBeNil)
+	test.That(t, vc.StopLoop(), test.ShouldBeNil)
+}
@@ -0,0 +1,225 @@
+package pour
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"go.viam.com/rdk/services/motion"
+)
+
+// seconds, shared by all the duration histograms
+var durationBuckets = []float64{.1, .25, .5, 1, 2.5, 5, 10, 20, 30, 60, 120, 300}
+
+// metricSeries is one labeled counter or histogram.
+type metricSeries struct {
+	labelValues []string
+
+	value float64 // counters
+
+	bucketCounts []uint64 // histograms, same length as buckets
+	sum          float64
+	count        uint64
+}
+
+type metric struct {
+	name    string
+	help    string
+	kind    string // "counter" or "histogram"
+	labels  []string
+	buckets []float64
+
+	series map[string]*metricSeries
+}
+
+func (m *metric) get(labelValues []string) *metricSeries {
+	if len(labelValues) != len(m.labels) {
+		panic(fmt.Sprintf("metric %s wants %d labels, got %d", m.name, len(m.labels), len(labelValues)))
+	}
+	key := strings.Join(labelValues, "\x00")
+	s, ok := m.series[key]
+	if !ok {
+		s = &metricSeries{labelValues: labelValues}
+		if m.kind == "histogram" {
+			s.bucketCounts = make([]uint64, len(m.buckets))
+		}
+		m.series[key] = s
+	}
+	return s
+}
+
+func (m *metric) write(w io.Writer) {
+	fmt.Fprintf(w, "# HELP %s %s\n", m.name, m.help)
+	fmt.Fprintf(w, "# TYPE %s %s\n", m.name, m.kind)
+
+	keys := []string{}
+	for k := range m.series {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		s := m.series[k]
+		if m.kind == "counter" {
+			fmt.Fprintf(w, "%s%s %v\n", m.name, formatLabels(m.labels, s.labelValues, "", ""), s.value)
+			continue
+		}
+
+		for i, le := range m.buckets {
+			fmt.Fprintf(w, "%s_bucket%s %d\n", m.name, formatLabels(m.labels, s.labelValues, "le", fmt.Sprintf("%v", le)), s.bucketCounts[i])
+		}
+		fmt.Fprintf(w, "%s_bucket%s %d\n", m.name, formatLabels(m.labels, s.labelValues, "le", "+Inf"), s.count)
+		fmt.Fprintf(w, "%s_sum%s %v\n", m.name, formatLabels(m.labels, s.labelValues, "", ""), s.sum)
+		fmt.Fprintf(w, "%s_count%s %d\n", m.name, formatLabels(m.labels, s.labelValues, "", ""), s.count)
+	}
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func formatLabels(names, values []string, extraName, extraValue string) string {
+	parts := []string{}
+	for i, n := range names {
+		parts = append(parts, fmt.Sprintf(`%s="%s"`, n, labelEscaper.Replace(values[i])))
+	}
+	if extraName != "" {
+		parts = append(parts, fmt.Sprintf(`%s="%s"`, extraName, labelEscaper.Replace(extraValue)))
+	}
+	if len(parts) == 0 {
+		return ""
+	}
+	return "{" + strings.Join(parts, ",") + "}"
+}
+
+// vinoMetrics is served in Prometheus text format on /metrics of the vinoweb server.
+type vinoMetrics struct {
+	lock    sync.Mutex
+	metrics []*metric
+
+	cyclesCompleted *metric
+	cyclesFailed    *metric
+	cycleDuration   *metric
+	stageDuration   *metric
+	planDuration    *metric
+	moveDuration    *metric
+	alignRetries    *metric
+	pickRejections  *metric
+	pourStops       *metric
+	tiltCache       *metric
+}
+
+func newVinoMetrics() *vinoMetrics {
+	m := &vinoMetrics{}
+
+	add := func(name, help, kind string, labels ...string) *metric {
+		x := &metric{name: name, help: help, kind: kind, labels: labels, series: map[string]*metricSeries{}}
+		if kind == "histogram" {
+			x.buckets = durationBuckets
+		}
+		m.metrics = append(m.metrics, x)
+		return x
+	}
+
+	m.cyclesCompleted = add("vinocart_cycles_completed_total", "Pour cycles that finished.", "counter")
+	m.cyclesFailed = add("vinocart_cycles_failed_total", "Pour cycles that failed, by the stage that was running.", "counter", "stage")
+	m.cycleDuration = add("vinocart_cycle_duration_seconds", "How long pour cycles take.", "histogram", "outcome")
+	m.stageDuration = add("vinocart_stage_duration_seconds", "How long each stage of a pour cycle takes.", "histogram", "stage")
+	m.planDuration = add("vinocart_plan_duration_seconds", "Motion planning latency by plan_tag.", "histogram", "plan_tag", "outcome")
+	m.moveDuration = add("vinocart_move_duration_seconds",
+		"Motion service moves by plan_tag, planning and executing the plan.", "histogram", "plan_tag", "outcome")
+	m.alignRetries = add("vinocart_alignment_retries_total", "Bottle to cup alignment replans.", "counter")
+	m.pickRejections = add("vinocart_pick_quality_rejections_total", "Picks the pick quality service said were bad.", "counter")
+	m.pourStops = add("vinocart_pour_stops_total", "Why pours stopped.", "counter", "reason")
+	m.tiltCache = add("vinocart_tilt_cache_total", "Pour tilt cache lookups, a hit skips planning the tilt.", "counter", "result")
+
+	return m
+}
+
+func (m *vinoMetrics) inc(x *metric, labelValues ...string) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	x.get(labelValues).value++
+}
+
+func (m *vinoMetrics) observe(x *metric, v float64, labelValues ...string) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	s := x.get(labelValues)
+	for i, le := range x.buckets {
+		if v <= le {
+			s.bucketCounts[i]++
+		}
+	}
+	s.sum += v
+	s.count++
+}
+
+func (m *vinoMetrics) observePlan(tag string, start time.Time, err error) {
+	m.observeTagged(m.planDuration, tag, start, err)
+}
+
+func (m *vinoMetrics) observeMove(tag string, start time.Time, err error) {
+	m.observeTagged(m.moveDuration, tag, start, err)
+}
+
+func (m *vinoMetrics) observeTagged(x *metric, tag string, start time.Time, err error) {
+	outcome := outcomeSuccess
+	if err != nil {
+		outcome = outcomeFailed
+	}
+	m.observe(x, time.Since(start).Seconds(), tag, outcome)
+}
+
+func (m *vinoMetrics) recordCycle(r *cycleRecord) {
+	m.observe(m.cycleDuration, r.End.Sub(r.Start).Seconds(), r.Outcome)
+	for stage, ms := range r.StageMs {
+		m.observe(m.stageDuration, ms/1000, stage)
+	}
+	if r.Outcome == outcomeSuccess {
+		m.inc(m.cyclesCompleted)
+	} else {
+		m.inc(m.cyclesFailed, r.FailedStage)
+	}
+}
+
+func (m *vinoMetrics) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	for _, x := range m.metrics {
+		x.write(w)
+	}
+}
+
+// timedMotion is the motion service with Move duration recorded by plan_tag, and
+// each Move recorded for replanning.
+type timedMotion struct {
+	motion.Service
+	metrics *vinoMetrics
+
+	// recordMove is called before each Move, and what it returns, if anything, after
+	recordMove func(ctx context.Context, req motion.MoveReq) func(ctx context.Context, tag string, start time.Time, err error)
+}
+
+func (tm *timedMotion) Move(ctx context.Context, req motion.MoveReq) (bool, error) {
+	tag, _ := req.Extra["plan_tag"].(string)
+	if tag == "" {
+		tag = "untagged"
+	}
+
+	done := tm.recordMove(ctx, req)
+
+	start := time.Now()
+	ok, err := tm.Service.Move(ctx, req)
+	tm.metrics.observeMove(tag, start, err)
+
+	if done != nil {
+		done(ctx, tag, start, err)
+	}
+	return ok, err
+}
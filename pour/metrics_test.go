@@ -0,0 +1,42 @@
+package pour
+
+import (
+	"context"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"go.viam.com/test"
+)
+
+func TestVinoMetricsText(t *testing.T) {
+	m := newVinoMetrics()
+	m.inc(m.pourStops, stopReasonMotion)
+	m.inc(m.pourStops, stopReasonMotion)
+	m.inc(m.cyclesFailed, `pour "loop"`)
+	m.observe(m.stageDuration, 3, "reset")
+
+	w := httptest.NewRecorder()
+	m.ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+	body := w.Body.String()
+
+	test.That(t, body, test.ShouldContainSubstring, "# TYPE vinocart_pour_stops_total counter\n")
+	test.That(t, body, test.ShouldContainSubstring, `vinocart_pour_stops_total{reason="motion_delta"} 2`+"\n")
+	test.That(t, body, test.ShouldContainSubstring, `vinocart_cycles_failed_total{stage="pour \"loop\""} 1`+"\n")
+	test.That(t, body, test.ShouldContainSubstring, `vinocart_stage_duration_seconds_bucket{stage="reset",le="2.5"} 0`+"\n")
+	test.That(t, body, test.ShouldContainSubstring, `vinocart_stage_duration_seconds_bucket{stage="reset",le="5"} 1`+"\n")
+	test.That(t, body, test.ShouldContainSubstring, `vinocart_stage_duration_seconds_bucket{stage="reset",le="+Inf"} 1`+"\n")
+	test.That(t, body, test.ShouldContainSubstring, `vinocart_stage_duration_seconds_count{stage="reset"} 1`+"\n")
+	test.That(t, strings.Count(body, "# HELP"), test.ShouldEqual, len(m.metrics))
+}
+
+func TestSimMoveMetrics(t *testing.T) {
+	vc, cart := newSimVinoCart(t)
+	putCup(t, cart, 450, 0)
+	test.That(t, vc.FullDemo(context.Background()), test.ShouldBeNil)
+
+	// motion service moves aren't counted as planning
+	test.That(t, vc.metrics.moveDuration.get([]string{"touch-pickup", outcomeSuccess}).count, test.ShouldBeGreaterThan, 0)
+	test.That(t, vc.metrics.planDuration.get([]string{"touch-pickup", outcomeSuccess}).count, test.ShouldEqual, 0)
+	test.That(t, vc.metrics.planDuration.get([]string{"touch-approach", outcomeSuccess}).count, test.ShouldBeGreaterThan, 0)
+}
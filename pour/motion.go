@@ -21,12 +21,9 @@ var LinearConstraint = motionplan.Constraints{
 	},
 }
 
+// SetXarmSpeed only works on xArms, VinoCart goes through its speed control instead.
 func SetXarmSpeed(ctx context.Context, a arm.Arm, speed, accel float64) error {
-	_, err := a.DoCommand(ctx, map[string]interface{}{
-		"set_speed":        float64(speed),
-		"set_acceleration": float64(accel),
-	})
-	return err
+	return newXarmSpeed(a).set(ctx, ArmSpeed{Speed: speed, Accel: accel})
 }
 
 func SetXarmSpeedLog(ctx context.Context, a arm.Arm, speed, accel float64, logger logging.Logger) {
@@ -53,7 +50,7 @@ func Jog(ctx context.Context, m motion.Service, n resource.Name, j r3.Vector) er
 		),
 	)
 
-	return moveWithLinearConstraint(ctx, m, n, goTo, "jog")
+	return moveWithLinearConstraint(ctx, m, n, goTo, nil, planTagExtra("jog"))
 }
 
 func JogJoint(ctx context.Context, a arm.Arm, j int, amount float64) error {
@@ -0,0 +1,190 @@
+package pour
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/golang/geo/r3"
+
+	"go.viam.com/rdk/referenceframe"
+	"go.viam.com/rdk/services/motion"
+	"go.viam.com/rdk/spatialmath"
+	viz "go.viam.com/rdk/vision"
+)
+
+const (
+	cupOrderDistance = "distance" // closest to the cup gripper first
+	cupOrderX        = "x"        // sweep along world x
+	cupOrderY        = "y"        // sweep along world y
+)
+
+// cupSpot is where a cup was picked up from, so it can go back there.
+type cupSpot struct {
+	obj    *viz.Object
+	o      *spatialmath.OrientationVectorDegrees
+	others []*viz.Object
+}
+
+// orderCups sorts cups for serving, from is the current gripper position.
+func orderCups(cups []*viz.Object, from r3.Vector, order string) []*viz.Object {
+	sorted := append([]*viz.Object{}, cups...)
+
+	key := func(o *viz.Object) float64 {
+		c := o.MetaData().Center()
+		switch order {
+		case cupOrderX:
+			return c.X
+		case cupOrderY:
+			return c.Y
+		default:
+			return r3.Vector{X: c.X - from.X, Y: c.Y - from.Y}.Norm()
+		}
+	}
+
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return key(sorted[i]) < key(sorted[j])
+	})
+	return sorted
+}
+
+func (vc *VinoCart) setCupQueue(index, count int) {
+	vc.queueLock.Lock()
+	defer vc.queueLock.Unlock()
+	vc.queueIndex = index
+	vc.queueCount = count
+	if count > 0 {
+		vc.logger.Infof("cup %d of %d", index, count)
+	}
+}
+
+func (vc *VinoCart) cupQueueStatus() map[string]interface{} {
+	vc.queueLock.Lock()
+	defer vc.queueLock.Unlock()
+	if vc.queueCount == 0 {
+		return nil
+	}
+	return map[string]interface{}{
+		"index":   vc.queueIndex,
+		"count":   vc.queueCount,
+		"message": fmt.Sprintf("cup %d of %d", vc.queueIndex, vc.queueCount),
+	}
+}
+
+// MultiCupDemo serves every cup on the table, putting each back where it was found.
+func (vc *VinoCart) MultiCupDemo(ctx context.Context) error {
+	defer vc.setCupQueue(0, 0)
+
+	vc.setStatus(StateLooking)
+
+	err := vc.Reset(ctx)
+	if err != nil {
+		return err
+	}
+
+	start := time.Now()
+	objects, err := vc.FindCups(ctx)
+	if err != nil {
+		return err
+	}
+	vc.logger.Infof("num objects: %v in %v", len(objects), time.Since(start))
+
+	if len(objects) == 0 {
+		return noObjects
+	}
+
+	gripperPose, err := vc.c.Motion.GetPose(ctx, vc.conf.GripperName, "world", nil, nil)
+	if err != nil {
+		return err
+	}
+	queue := orderCups(objects, gripperPose.Pose().Point(), vc.conf.cupOrder())
+	vc.recordCycle(func(r *cycleRecord) {
+		r.CupCount = len(queue)
+	})
+
+	served := 0
+	for idx, obj := range queue {
+		vc.setCupQueue(idx+1, len(queue))
+
+		others := []*viz.Object{}
+		for _, other := range queue {
+			if other != obj {
+				others = append(others, other)
+			}
+		}
+
+		err := vc.serveCup(ctx, obj, others)
+		if err == nil {
+			served++
+			continue
+		}
+
+		// if we're still holding something we can't just move on
+		holding, herr := vc.c.Gripper.IsHoldingSomething(ctx, nil)
+		if herr != nil {
+			return fmt.Errorf("cup %d of %d failed (%w) and can't check gripper: %w", idx+1, len(queue), err, herr)
+		}
+		if holding.IsHoldingSomething || ctx.Err() != nil {
+			return fmt.Errorf("cup %d of %d: %w", idx+1, len(queue), err)
+		}
+		vc.logger.Warnf("skipping cup %d of %d: %v", idx+1, len(queue), err)
+	}
+
+	if served == 0 {
+		return fmt.Errorf("couldn't serve any of %d cups", len(queue))
+	}
+	return nil
+}
+
+func (vc *VinoCart) serveCup(ctx context.Context, obj *viz.Object, others []*viz.Object) error {
+	// a handoff moves the cup, and then it can't go back where it came from
+	o, err := vc.touchCup(ctx, obj, others, false)
+	if err != nil {
+		return err
+	}
+
+	err = vc.PourPrep(ctx)
+	if err != nil {
+		return err
+	}
+
+	err = vc.Pour(ctx)
+	if err != nil {
+		return err
+	}
+
+	return vc.putBack(ctx, &cupSpot{obj: obj, o: o, others: others})
+}
+
+func (vc *VinoCart) cupSpotWorldState(spot *cupSpot) (*referenceframe.WorldState, error) {
+	return vc.worldState(otherCupObstacles(spot.others)...)
+}
+
+// moveToCupSpot brings the held cup back to where it was picked up.
+func (vc *VinoCart) moveToCupSpot(ctx context.Context, spot *cupSpot) error {
+	worldState, err := vc.cupSpotWorldState(spot)
+	if err != nil {
+		return err
+	}
+
+	_, err = vc.c.Motion.Move(
+		ctx,
+		motion.MoveReq{
+			ComponentName: vc.c.Gripper.Name().ShortName(),
+			Destination:   vc.getApproachPoint(spot.obj, 100, spot.o),
+			WorldState:    worldState,
+			Extra:         vc.moveExtra(vc.c.Arm, "put-back-approach"),
+		},
+	)
+	if err != nil {
+		return err
+	}
+
+	return moveWithLinearConstraint(ctx, vc.c.Motion, vc.c.Gripper.Name(), vc.getApproachPoint(spot.obj, vc.conf.gripperToCupCenter(), spot.o), vc.obstacles, vc.moveExtra(vc.c.Arm, "put-back-spot"))
+}
+
+// retreatFromCupSpot backs the open gripper away from a cup it just put down.
+func (vc *VinoCart) retreatFromCupSpot(ctx context.Context, spot *cupSpot) error {
+	return moveWithLinearConstraint(ctx, vc.c.Motion, vc.c.Gripper.Name(), vc.getApproachPoint(spot.obj, 100, spot.o), vc.obstacles, vc.moveExtra(vc.c.Arm, "put-back-retreat"))
+}
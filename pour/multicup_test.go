@@ -0,0 +1,92 @@
+package pour
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/golang/geo/r3"
+	"go.viam.com/test"
+
+	"go.viam.com/rdk/referenceframe"
+	"go.viam.com/rdk/services/motion"
+	"go.viam.com/rdk/spatialmath"
+	viz "go.viam.com/rdk/vision"
+
+	"github.com/viam-modules/viam-pouring-demo/pour/sim"
+)
+
+func TestOrderCups(t *testing.T) {
+	cup := func(x, y float64) *viz.Object {
+		c, err := sim.NewCup(x, y, 110, 60)
+		test.That(t, err, test.ShouldBeNil)
+		return c
+	}
+	near := cup(300, 300)
+	far := cup(600, -400)
+	middle := cup(450, 0)
+	cups := []*viz.Object{far, near, middle}
+
+	test.That(t, orderCups(cups, r3.Vector{X: 300, Y: 200, Z: 500}, cupOrderDistance), test.ShouldResemble, []*viz.Object{near, middle, far})
+	test.That(t, orderCups(cups, r3.Vector{X: 600, Y: -300}, cupOrderDistance), test.ShouldResemble, []*viz.Object{far, middle, near})
+	test.That(t, orderCups(cups, r3.Vector{}, cupOrderX), test.ShouldResemble, []*viz.Object{near, middle, far})
+	test.That(t, orderCups(cups, r3.Vector{}, cupOrderY), test.ShouldResemble, []*viz.Object{far, middle, near})
+
+	// doesn't touch the cups it was given
+	test.That(t, cups, test.ShouldResemble, []*viz.Object{far, near, middle})
+}
+
+// tagMoves remembers the destination and world of every motion service move by plan_tag.
+type tagMoves struct {
+	motion.Service
+
+	lock   sync.Mutex
+	moves  map[string][]*referenceframe.PoseInFrame
+	worlds map[string][]*referenceframe.WorldState
+}
+
+func (tm *tagMoves) Move(ctx context.Context, req motion.MoveReq) (bool, error) {
+	ok, err := tm.Service.Move(ctx, req)
+	if err == nil {
+		tag, _ := req.Extra["plan_tag"].(string)
+		tm.lock.Lock()
+		tm.moves[tag] = append(tm.moves[tag], req.Destination)
+		tm.worlds[tag] = append(tm.worlds[tag], req.WorldState)
+		tm.lock.Unlock()
+	}
+	return ok, err
+}
+
+func TestSimMultiCupDemo(t *testing.T) {
+	ctx := context.Background()
+	vc, cart := newSimVinoCart(t)
+	a, err := sim.NewCup(450, 0, 110, 60)
+	test.That(t, err, test.ShouldBeNil)
+	b, err := sim.NewCup(450, 200, 110, 60)
+	test.That(t, err, test.ShouldBeNil)
+	cart.CupFinder.SetObjects(a, b)
+
+	moves := &tagMoves{
+		Service: vc.c.Motion,
+		moves:   map[string][]*referenceframe.PoseInFrame{},
+		worlds:  map[string][]*referenceframe.WorldState{},
+	}
+	vc.c.Motion = moves
+	vc.conf.MultiCup = true
+
+	test.That(t, vc.FullDemo(ctx), test.ShouldBeNil)
+	test.That(t, cart.Gripper.Holding(), test.ShouldBeFalse)
+
+	// each cup goes down where it was picked up, not where the one before it was
+	picked := moves.moves["touch-pickup"]
+	placed := moves.moves["put-back-spot"]
+	test.That(t, len(picked), test.ShouldEqual, 2)
+	test.That(t, len(placed), test.ShouldEqual, 2)
+	for i := range picked {
+		test.That(t, spatialmath.PoseAlmostEqual(placed[i].Pose(), picked[i].Pose()), test.ShouldBeTrue)
+	}
+	test.That(t, placed[0].Pose().Point().Distance(placed[1].Pose().Point()), test.ShouldBeGreaterThan, 100)
+	// the first pickup goes down next to the cup still waiting
+	test.That(t, moves.worlds["touch-pickup"][0].ObstacleNames(), test.ShouldContainKey, "other-cup-0")
+	test.That(t, vc.cupQueueStatus(), test.ShouldBeNil)
+}
@@ -0,0 +1,309 @@
+package pour
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync"
+
+	"golang.org/x/sync/errgroup"
+
+	"github.com/golang/geo/r3"
+	commonpb "go.viam.com/api/common/v1"
+
+	"go.viam.com/rdk/components/arm"
+	"go.viam.com/rdk/referenceframe"
+	"go.viam.com/rdk/spatialmath"
+
+	"github.com/erh/vmodutils/touch"
+)
+
+// obstacle types
+const (
+	obstacleBox     = "box"
+	obstacleCapsule = "capsule"
+	obstacleMesh    = "mesh"
+)
+
+// Obstacle is something fixed on or around the cart the arms must not hit, like
+// the table, the bottle holder or the display.
+type Obstacle struct {
+	Name string `json:"name"`
+	// frame the pose is in, world if unset
+	Frame       string                                `json:"frame,omitempty"`
+	Type        string                                `json:"type"`
+	Translation r3.Vector                             `json:"translation"`
+	Orientation *spatialmath.OrientationVectorDegrees `json:"orientation,omitempty"`
+
+	// box
+	DimsMM *r3.Vector `json:"dims_mm,omitempty"`
+	// capsule, along its z axis
+	RadiusMM float64 `json:"radius_mm,omitempty"`
+	LengthMM float64 `json:"length_mm,omitempty"`
+	// mesh, a .ply file
+	MeshFile string `json:"mesh_file,omitempty"`
+}
+
+func (o *Obstacle) frame() string {
+	if o.Frame != "" {
+		return o.Frame
+	}
+	return referenceframe.World
+}
+
+func (o *Obstacle) pose() spatialmath.Pose {
+	if o.Orientation == nil {
+		return spatialmath.NewPoseFromPoint(o.Translation)
+	}
+	return spatialmath.NewPose(o.Translation, o.Orientation)
+}
+
+func (o *Obstacle) geometry() (spatialmath.Geometry, error) {
+	switch o.Type {
+	case obstacleBox:
+		if o.DimsMM == nil {
+			return nil, fmt.Errorf("needs dims_mm")
+		}
+		return spatialmath.NewBox(o.pose(), *o.DimsMM, o.Name)
+	case obstacleCapsule:
+		return spatialmath.NewCapsule(o.pose(), o.RadiusMM, o.LengthMM, o.Name)
+	case obstacleMesh:
+		if o.MeshFile == "" {
+			return nil, fmt.Errorf("needs mesh_file")
+		}
+		data, err := os.ReadFile(o.MeshFile)
+		if err != nil {
+			return nil, err
+		}
+		return spatialmath.NewGeometryFromProto(&commonpb.Geometry{
+			Center:       spatialmath.PoseToProtobuf(o.pose()),
+			GeometryType: &commonpb.Geometry_Mesh{Mesh: &commonpb.Mesh{ContentType: "ply", Mesh: data}},
+			Label:        o.Name,
+		})
+	default:
+		return nil, fmt.Errorf("type must be %q, %q or %q, not %q", obstacleBox, obstacleCapsule, obstacleMesh, o.Type)
+	}
+}
+
+// obstacleGeometries is every configured obstacle, grouped by frame.
+func (cfg *Config) obstacleGeometries() ([]*referenceframe.GeometriesInFrame, error) {
+	byFrame := map[string][]spatialmath.Geometry{}
+	frames := []string{}
+	names := map[string]bool{}
+	for idx, o := range cfg.Obstacles {
+		if o.Name == "" {
+			return nil, fmt.Errorf("obstacles.%d needs a name", idx)
+		}
+		if names[o.Name] {
+			return nil, fmt.Errorf("obstacles.%d: there's already an obstacle named %q", idx, o.Name)
+		}
+		names[o.Name] = true
+
+		g, err := o.geometry()
+		if err != nil {
+			return nil, fmt.Errorf("obstacles.%d (%s): %w", idx, o.Name, err)
+		}
+		f := o.frame()
+		if _, ok := byFrame[f]; !ok {
+			frames = append(frames, f)
+		}
+		byFrame[f] = append(byFrame[f], g)
+	}
+
+	gifs := []*referenceframe.GeometriesInFrame{}
+	for _, f := range frames {
+		gifs = append(gifs, referenceframe.NewGeometriesInFrame(f, byFrame[f]))
+	}
+	return gifs, nil
+}
+
+// worldState is the configured obstacles plus more, for a Motion.Move.
+func (vc *VinoCart) worldState(more ...*referenceframe.GeometriesInFrame) (*referenceframe.WorldState, error) {
+	all := append(append([]*referenceframe.GeometriesInFrame{}, vc.obstacles...), more...)
+	return referenceframe.NewWorldState(all, nil)
+}
+
+// planWorldState is worldState for armplanning requests on part of the cart.
+// Obstacles in frames fs doesn't have are moved into world, where they are now.
+func (vc *VinoCart) planWorldState(ctx context.Context, fs *referenceframe.FrameSystem, more ...*referenceframe.GeometriesInFrame) (*referenceframe.WorldState, error) {
+	var full *referenceframe.FrameSystem
+	var fullInputs referenceframe.FrameSystemInputs
+	var fullErr error
+
+	all := []*referenceframe.GeometriesInFrame{}
+	for _, gif := range append(append([]*referenceframe.GeometriesInFrame{}, vc.obstacles...), more...) {
+		if gif.Parent() == referenceframe.World || fs.Frame(gif.Parent()) != nil {
+			all = append(all, gif)
+			continue
+		}
+
+		if full == nil && fullErr == nil {
+			full, fullInputs, fullErr = vc.fullFrameSystem(ctx)
+		}
+		if fullErr != nil {
+			vc.logger.Warnf("leaving out obstacles in %s, can't get the whole frame system: %v", gif.Parent(), fullErr)
+			continue
+		}
+		inWorld, err := transformGeometries(full, fullInputs, gif)
+		if err != nil {
+			vc.logger.Warnf("leaving out obstacles in %s: %v", gif.Parent(), err)
+			continue
+		}
+		all = append(all, inWorld)
+	}
+	return referenceframe.NewWorldState(all, nil)
+}
+
+// fullFrameSystem is the robot's whole frame system, with both arms where they are now.
+func (vc *VinoCart) fullFrameSystem(ctx context.Context) (*referenceframe.FrameSystem, referenceframe.FrameSystemInputs, error) {
+	cfg, err := vc.c.Rfs.FrameSystemConfig(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	fs, err := referenceframe.NewFrameSystem("full", cfg.Parts, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	inputs, err := vc.armInputs(ctx, fs)
+	if err != nil {
+		return nil, nil, err
+	}
+	return fs, inputs, nil
+}
+
+func transformGeometries(fs *referenceframe.FrameSystem, inputs referenceframe.FrameSystemInputs, gif *referenceframe.GeometriesInFrame) (*referenceframe.GeometriesInFrame, error) {
+	tf, err := fs.Transform(inputs, gif, referenceframe.World)
+	if err != nil {
+		return nil, err
+	}
+	inWorld, ok := tf.(*referenceframe.GeometriesInFrame)
+	if !ok {
+		return nil, fmt.Errorf("transform of %s gave a %T", gif.Parent(), tf)
+	}
+	return inWorld, nil
+}
+
+// cartFrameSystem is both arms with their grippers and the cup and bottle tops.
+func (vc *VinoCart) cartFrameSystem(ctx context.Context) (*referenceframe.FrameSystem, error) {
+	parts := []string{}
+	transforms := []*referenceframe.LinkInFrame{}
+	for _, x := range []struct {
+		a       arm.Arm
+		gripper string
+		top     *referenceframe.LinkInFrame
+	}{
+		{vc.c.Arm, vc.conf.GripperName, vc.cupTop},
+		{vc.c.BottleArm, vc.conf.BottleGripper, vc.bottleTop},
+	} {
+		if x.a == nil {
+			continue
+		}
+		parts = append(parts, x.a.Name().ShortName())
+		if x.gripper != "" {
+			parts = append(parts, x.gripper)
+			transforms = append(transforms, x.top)
+		}
+	}
+	return touch.FrameSystemWithSomeParts(ctx, vc.c.Rfs, parts, transforms)
+}
+
+// armInputs is where both arms are now, asked at the same time, with inputs
+// for the rest of fs.
+func (vc *VinoCart) armInputs(ctx context.Context, fs *referenceframe.FrameSystem) (referenceframe.FrameSystemInputs, error) {
+	var lock sync.Mutex
+	inputs := referenceframe.FrameSystemInputs{}
+
+	g := errgroup.Group{}
+	for _, a := range []arm.Arm{vc.c.Arm, vc.c.BottleArm} {
+		if a == nil {
+			continue
+		}
+		g.Go(func() error {
+			joints, err := a.JointPositions(ctx, nil)
+			if err != nil {
+				return err
+			}
+			lock.Lock()
+			defer lock.Unlock()
+			inputs[a.Name().ShortName()] = joints
+			return nil
+		})
+	}
+	if err := g.Wait(); err != nil {
+		return nil, err
+	}
+	return withStaticInputs(fs, inputs), nil
+}
+
+// withStaticInputs is inputs with empty inputs added for the frames in fs that don't move.
+func withStaticInputs(fs *referenceframe.FrameSystem, inputs referenceframe.FrameSystemInputs) referenceframe.FrameSystemInputs {
+	all := referenceframe.FrameSystemInputs{}
+	for name, in := range inputs {
+		all[name] = in
+	}
+	for _, name := range fs.FrameNames() {
+		if _, ok := all[name]; !ok && len(fs.Frame(name).DoF()) == 0 {
+			all[name] = []referenceframe.Input{}
+		}
+	}
+	return all
+}
+
+// describeWorldState is the configured obstacles and, with cups, the cups on the table
+// as a pick would see them.
+func (vc *VinoCart) describeWorldState(ctx context.Context, cups bool) (map[string]interface{}, error) {
+	vc.confLock.RLock()
+	defer vc.confLock.RUnlock()
+
+	gifs := append([]*referenceframe.GeometriesInFrame{}, vc.obstacles...)
+	if cups {
+		objects, err := vc.FindCups(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for idx, o := range objects {
+			o.Geometry.SetLabel(fmt.Sprintf("cup-%d", idx))
+			gifs = append(gifs, referenceframe.NewGeometriesInFrame(referenceframe.World, []spatialmath.Geometry{o.Geometry}))
+		}
+	}
+
+	obstacles := []map[string]interface{}{}
+	for _, gif := range gifs {
+		for _, g := range gif.Geometries() {
+			obstacles = append(obstacles, describeGeometry(gif.Parent(), g))
+		}
+	}
+	return map[string]interface{}{"obstacles": obstacles}, nil
+}
+
+func describeGeometry(frame string, g spatialmath.Geometry) map[string]interface{} {
+	p := g.Pose()
+	o := p.Orientation().OrientationVectorDegrees()
+	m := map[string]interface{}{
+		"label":       g.Label(),
+		"frame":       frame,
+		"translation": vectorMap(p.Point()),
+		"orientation": map[string]interface{}{"ox": o.OX, "oy": o.OY, "oz": o.OZ, "theta": o.Theta},
+	}
+
+	pb := g.ToProtobuf()
+	switch {
+	case pb.GetBox() != nil:
+		d := pb.GetBox().GetDimsMm()
+		m["type"] = obstacleBox
+		m["dims_mm"] = vectorMap(r3.Vector{X: d.GetX(), Y: d.GetY(), Z: d.GetZ()})
+	case pb.GetCapsule() != nil:
+		m["type"] = obstacleCapsule
+		m["radius_mm"] = pb.GetCapsule().GetRadiusMm()
+		m["length_mm"] = pb.GetCapsule().GetLengthMm()
+	case pb.GetSphere() != nil:
+		m["type"] = "sphere"
+		m["radius_mm"] = pb.GetSphere().GetRadiusMm()
+	case pb.GetMesh() != nil:
+		m["type"] = obstacleMesh
+		m["mesh_bytes"] = len(pb.GetMesh().GetMesh())
+	default:
+		m["type"] = "other"
+	}
+	return m
+}
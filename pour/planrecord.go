@@ -0,0 +1,458 @@
+package pour
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	commonpb "go.viam.com/api/common/v1"
+
+	"go.viam.com/rdk/logging"
+	"go.viam.com/rdk/motionplan"
+	"go.viam.com/rdk/motionplan/armplanning"
+	"go.viam.com/rdk/referenceframe"
+	"go.viam.com/rdk/services/motion"
+)
+
+// defaultPlanRecordDir is where every plan is recorded. It's not in planDir so
+// data manager syncing planDir doesn't upload all of them.
+var defaultPlanRecordDir = func() string {
+	cacheDir, err := os.UserCacheDir()
+	if err != nil {
+		return "/tmp/viam-plan-records"
+	}
+
+	return filepath.Join(cacheDir, "viam-plan-records")
+}()
+
+const defaultPlanRecordLimit = 500
+
+// where a plan was made
+const (
+	planSourceArmplanning = "armplanning" // by us
+	planSourceMotion      = "motion"      // by the motion service, the request is our closest guess
+)
+
+// PlanRecord is one plan VinoCart made, with the request so it can be replanned.
+type PlanRecord struct {
+	Tag        string    `json:"tag"`
+	Source     string    `json:"source"`
+	Time       time.Time `json:"time"`
+	DurationMs float64   `json:"duration_ms"`
+	Outcome    string    `json:"outcome"`
+	Error      string    `json:"error,omitempty"`
+
+	// a serialized armplanning.PlanRequest
+	Request json.RawMessage `json:"request,omitempty"`
+	// why there's no request
+	RequestError string `json:"request_error,omitempty"`
+
+	// motion service moves don't return one
+	Trajectory motionplan.Trajectory `json:"trajectory,omitempty"`
+}
+
+func newPlanRecord(tag, source string, start time.Time, err error) *PlanRecord {
+	r := &PlanRecord{
+		Tag:        tag,
+		Source:     source,
+		Time:       start,
+		DurationMs: float64(time.Since(start).Microseconds()) / 1000,
+		Outcome:    outcomeSuccess,
+	}
+	if err != nil {
+		r.Outcome = outcomeFailed
+		r.Error = err.Error()
+	}
+	return r
+}
+
+// ReadPlanRecord reads a file written by the plan recorder.
+func ReadPlanRecord(fn string) (*PlanRecord, error) {
+	data, err := os.ReadFile(fn)
+	if err != nil {
+		return nil, err
+	}
+	r := &PlanRecord{}
+	err = json.Unmarshal(data, r)
+	if err != nil {
+		return nil, fmt.Errorf("bad plan record %s: %w", fn, err)
+	}
+	return r, nil
+}
+
+// PlanRequest is the recorded request.
+func (r *PlanRecord) PlanRequest() (*armplanning.PlanRequest, error) {
+	if len(r.Request) == 0 {
+		return nil, fmt.Errorf("%s has no request: %s", r.Tag, r.RequestError)
+	}
+	req := &armplanning.PlanRequest{}
+	err := json.Unmarshal(r.Request, req)
+	if err != nil {
+		return nil, fmt.Errorf("bad request for %s: %w", r.Tag, err)
+	}
+	return req, nil
+}
+
+// how many records can wait to be written before new ones are dropped
+const planRecordQueue = 64
+
+type planRecordWrite struct {
+	r   *PlanRecord
+	req *armplanning.PlanRequest
+}
+
+// planRecorder writes a PlanRecord file per plan, keeping the newest limit of
+// them. Writing is in the background so it doesn't slow down planning.
+type planRecorder struct {
+	logger logging.Logger
+
+	queue   chan planRecordWrite
+	pending sync.WaitGroup
+	done    chan struct{}
+
+	lock   sync.Mutex
+	dir    string
+	limit  int
+	seq    int
+	closed bool
+}
+
+func newPlanRecorder(dir string, limit int, logger logging.Logger) *planRecorder {
+	pr := &planRecorder{
+		logger: logger,
+		queue:  make(chan planRecordWrite, planRecordQueue),
+		done:   make(chan struct{}),
+	}
+	pr.configure(dir, limit)
+	go pr.writeLoop()
+	return pr
+}
+
+// configure changes where records go and how many are kept, a negative limit records none.
+func (pr *planRecorder) configure(dir string, limit int) {
+	if dir == "" {
+		dir = defaultPlanRecordDir
+	}
+	if limit == 0 {
+		limit = defaultPlanRecordLimit
+	}
+	pr.lock.Lock()
+	defer pr.lock.Unlock()
+	pr.dir = dir
+	pr.limit = limit
+}
+
+func (pr *planRecorder) enabled() bool {
+	pr.lock.Lock()
+	defer pr.lock.Unlock()
+	return pr.limit > 0
+}
+
+var planRecordUnsafe = regexp.MustCompile(`[^a-zA-Z0-9_-]+`)
+
+// record queues r with req to be written, problems are logged rather than
+// failing the plan.
+func (pr *planRecorder) record(r *PlanRecord, req *armplanning.PlanRequest) {
+	pr.lock.Lock()
+	defer pr.lock.Unlock()
+	if pr.closed || pr.limit <= 0 {
+		return
+	}
+
+	pr.pending.Add(1)
+	select {
+	case pr.queue <- planRecordWrite{r, req}:
+	default:
+		pr.pending.Done()
+		pr.logger.Warnf("too many plan records waiting, dropping %s", r.Tag)
+	}
+}
+
+// flush waits for every queued record to be written.
+func (pr *planRecorder) flush() {
+	pr.pending.Wait()
+}
+
+// close writes what's queued and stops writing.
+func (pr *planRecorder) close() {
+	pr.lock.Lock()
+	if !pr.closed {
+		pr.closed = true
+		close(pr.queue)
+	}
+	pr.lock.Unlock()
+	<-pr.done
+}
+
+func (pr *planRecorder) writeLoop() {
+	defer close(pr.done)
+	for w := range pr.queue {
+		pr.write(w.r, w.req)
+		pr.pending.Done()
+	}
+}
+
+func (pr *planRecorder) write(r *PlanRecord, req *armplanning.PlanRequest) {
+	if req != nil {
+		data, err := json.Marshal(req)
+		if err != nil {
+			r.RequestError = err.Error()
+		} else {
+			r.Request = data
+		}
+	}
+	data, err := json.Marshal(r)
+	if err != nil {
+		pr.logger.Warnf("can't record %s plan: %v", r.Tag, err)
+		return
+	}
+
+	pr.lock.Lock()
+	defer pr.lock.Unlock()
+
+	err = os.MkdirAll(pr.dir, 0o755)
+	if err != nil {
+		pr.logger.Warnf("can't record %s plan: %v", r.Tag, err)
+		return
+	}
+
+	pr.seq = (pr.seq + 1) % 10000
+	fn := filepath.Join(pr.dir, fmt.Sprintf("plan-%s-%04d-%s.json",
+		r.Time.UTC().Format("20060102-150405.000000"), pr.seq, planRecordUnsafe.ReplaceAllString(r.Tag, "_")))
+	err = os.WriteFile(fn, data, 0o644)
+	if err != nil {
+		pr.logger.Warnf("can't record %s plan: %v", r.Tag, err)
+		return
+	}
+
+	err = pr.pruneLocked()
+	if err != nil {
+		pr.logger.Warnf("can't prune plan records: %v", err)
+	}
+}
+
+// pruneLocked removes the oldest records past the limit.
+func (pr *planRecorder) pruneLocked() error {
+	files, err := planRecordFiles(pr.dir)
+	if err != nil {
+		return err
+	}
+	for len(files) > pr.limit {
+		err = os.Remove(files[0])
+		if err != nil {
+			return err
+		}
+		files = files[1:]
+	}
+	return nil
+}
+
+// planRecordFiles is every record in dir, oldest first.
+func planRecordFiles(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	files := []string{}
+	for _, e := range entries {
+		if !e.IsDir() && strings.HasPrefix(e.Name(), "plan-") && strings.HasSuffix(e.Name(), ".json") {
+			files = append(files, filepath.Join(dir, e.Name()))
+		}
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+// PlanRecordFiles is fn if it's a file, or every record in it, oldest first.
+func PlanRecordFiles(fn string) ([]string, error) {
+	fi, err := os.Stat(fn)
+	if err != nil {
+		return nil, err
+	}
+	if !fi.IsDir() {
+		return []string{fn}, nil
+	}
+	return planRecordFiles(fn)
+}
+
+// recordMove starts recording a motion service move for req, returning what to
+// call when it's done, or nil if plans aren't recorded.
+func (vc *VinoCart) recordMove(ctx context.Context, req motion.MoveReq) func(ctx context.Context, tag string, start time.Time, err error) {
+	if !vc.plans.enabled() {
+		return nil
+	}
+
+	// the request is from where the arms are before moving
+	fs, err := vc.moveRecordFrameSystem(ctx)
+	var inputs referenceframe.FrameSystemInputs
+	if err == nil {
+		inputs, err = vc.armInputs(ctx, fs)
+	}
+	var planReq *armplanning.PlanRequest
+	if err == nil {
+		planReq, err = planRequestForMove(fs, inputs, req)
+	}
+	reqErr := err
+
+	return func(ctx context.Context, tag string, start time.Time, err error) {
+		r := newPlanRecord(tag, planSourceMotion, start, err)
+		if reqErr != nil {
+			r.RequestError = reqErr.Error()
+		}
+		if err == nil && reqErr == nil {
+			// the motion service doesn't say how it got there, only where it started and ended
+			end, err := vc.armInputs(ctx, fs)
+			if err != nil {
+				vc.logger.Debugf("no trajectory for %s: %v", tag, err)
+			} else {
+				r.Trajectory = motionplan.Trajectory{inputs, end}
+			}
+		}
+		vc.plans.record(r, planReq)
+	}
+}
+
+// moveRecordFrameSystem is cartFrameSystem, kept until the config changes so
+// recording a move doesn't ask the robot for it every time.
+func (vc *VinoCart) moveRecordFrameSystem(ctx context.Context) (*referenceframe.FrameSystem, error) {
+	vc.moveRecordLock.Lock()
+	defer vc.moveRecordLock.Unlock()
+	if vc.moveRecordFs != nil {
+		return vc.moveRecordFs, nil
+	}
+	fs, err := vc.cartFrameSystem(ctx)
+	if err != nil {
+		return nil, err
+	}
+	vc.moveRecordFs = fs
+	return fs, nil
+}
+
+// planRequestForMove is the armplanning request closest to what the motion
+// service plans for req, from inputs.
+func planRequestForMove(fs *referenceframe.FrameSystem, inputs referenceframe.FrameSystemInputs, req motion.MoveReq) (*armplanning.PlanRequest, error) {
+	worldState, err := worldStateInFrameSystem(fs, req.WorldState)
+	if err != nil {
+		return nil, err
+	}
+
+	return &armplanning.PlanRequest{
+		FrameSystem: fs,
+		Goals: []*armplanning.PlanState{
+			armplanning.NewPlanState(referenceframe.FrameSystemPoses{req.ComponentName: req.Destination}, nil),
+		},
+		StartState:  armplanning.NewPlanState(nil, inputs),
+		WorldState:  worldState,
+		Constraints: req.Constraints,
+	}, nil
+}
+
+// worldStateInFrameSystem is ws without obstacles in frames fs doesn't have.
+func worldStateInFrameSystem(fs *referenceframe.FrameSystem, ws *referenceframe.WorldState) (*referenceframe.WorldState, error) {
+	if ws == nil {
+		return nil, nil
+	}
+	pb, err := ws.ToProtobuf()
+	if err != nil {
+		return nil, err
+	}
+	obstacles := []*commonpb.GeometriesInFrame{}
+	for _, o := range pb.GetObstacles() {
+		f := o.GetReferenceFrame()
+		if f != "" && f != referenceframe.World && fs.Frame(f) == nil {
+			continue
+		}
+		obstacles = append(obstacles, o)
+	}
+	pb.Obstacles = obstacles
+	return referenceframe.WorldStateFromProtobuf(pb)
+}
+
+// ReplanResult is a recorded plan planned again.
+type ReplanResult struct {
+	Record     *PlanRecord
+	Duration   time.Duration
+	Err        error
+	Trajectory motionplan.Trajectory
+}
+
+// Replan plans r's request again, offline.
+func Replan(ctx context.Context, logger logging.Logger, r *PlanRecord) (*ReplanResult, error) {
+	req, err := r.PlanRequest()
+	if err != nil {
+		return nil, err
+	}
+
+	start := time.Now()
+	plan, _, err := armplanning.PlanMotion(ctx, logger, req)
+	res := &ReplanResult{Record: r, Duration: time.Since(start), Err: err}
+	if err == nil {
+		res.Trajectory = plan.Trajectory()
+	}
+	return res, nil
+}
+
+func (rr *ReplanResult) outcome() string {
+	if rr.Err != nil {
+		return outcomeFailed
+	}
+	return outcomeSuccess
+}
+
+// OutcomeChanged is true if one of the plans failed and the other didn't.
+func (rr *ReplanResult) OutcomeChanged() bool {
+	return rr.outcome() != rr.Record.Outcome
+}
+
+func (rr *ReplanResult) String() string {
+	r := rr.Record
+	s := fmt.Sprintf("%s (%s) recorded %s in %.0fms", r.Tag, r.Source, r.Outcome, r.DurationMs)
+	if len(r.Trajectory) > 0 {
+		s += fmt.Sprintf(" %d steps travel %.3f", len(r.Trajectory), trajectoryTravel(r.Trajectory))
+	}
+
+	s += fmt.Sprintf(", now %s in %.0fms", rr.outcome(), float64(rr.Duration.Microseconds())/1000)
+	if rr.Err != nil {
+		return s + ": " + rr.Err.Error()
+	}
+	s += fmt.Sprintf(" %d steps travel %.3f", len(rr.Trajectory), trajectoryTravel(rr.Trajectory))
+	if len(r.Trajectory) > 0 {
+		s += fmt.Sprintf(", ends %.3f apart", trajectoryEndDistance(r.Trajectory, rr.Trajectory))
+	}
+	return s
+}
+
+// trajectoryTravel is the joint distance covered by every frame, in radians.
+func trajectoryTravel(traj motionplan.Trajectory) float64 {
+	travel := 0.
+	for i := 1; i < len(traj); i++ {
+		for name, inputs := range traj[i] {
+			if prev, ok := traj[i-1][name]; ok && len(prev) == len(inputs) {
+				travel += referenceframe.InputsL2Distance(prev, inputs)
+			}
+		}
+	}
+	return travel
+}
+
+// trajectoryEndDistance is how far apart the last steps of a and b are, over the frames in both.
+func trajectoryEndDistance(a, b motionplan.Trajectory) float64 {
+	if len(a) == 0 || len(b) == 0 {
+		return 0
+	}
+	endA, endB := a[len(a)-1], b[len(b)-1]
+	d := 0.
+	for name, inputs := range endA {
+		if other, ok := endB[name]; ok && len(other) == len(inputs) {
+			d += referenceframe.InputsL2Distance(inputs, other)
+		}
+	}
+	return d
+}
@@ -0,0 +1,93 @@
+package pour
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"go.viam.com/test"
+
+	"go.viam.com/rdk/logging"
+)
+
+func TestPlanRecorder(t *testing.T) {
+	dir := t.TempDir()
+	pr := newPlanRecorder(dir, 2, logging.NewTestLogger(t))
+
+	start := time.Now()
+	for i, tag := range []string{"a", "b/c", "d"} {
+		var err error
+		if i == 2 {
+			err = errors.New("no path")
+		}
+		pr.record(newPlanRecord(tag, planSourceArmplanning, start.Add(time.Duration(i)*time.Second), err), nil)
+	}
+	pr.flush()
+
+	files, err := PlanRecordFiles(dir)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, len(files), test.ShouldEqual, 2)
+	test.That(t, filepath.Base(files[0]), test.ShouldEndWith, "-b_c.json")
+
+	r, err := ReadPlanRecord(files[1])
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, r.Tag, test.ShouldEqual, "d")
+	test.That(t, r.Outcome, test.ShouldEqual, outcomeFailed)
+	test.That(t, r.Error, test.ShouldEqual, "no path")
+	_, err = r.PlanRequest()
+	test.That(t, err, test.ShouldNotBeNil)
+
+	pr.configure(dir, -1)
+	pr.record(newPlanRecord("e", planSourceMotion, start, nil), nil)
+	pr.flush()
+	files, err = PlanRecordFiles(dir)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, len(files), test.ShouldEqual, 2)
+
+	// what's queued is written on close, nothing after
+	pr.configure(dir, 10)
+	pr.record(newPlanRecord("f", planSourceMotion, start, nil), nil)
+	pr.close()
+	pr.record(newPlanRecord("g", planSourceMotion, start, nil), nil)
+	files, err = PlanRecordFiles(dir)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, len(files), test.ShouldEqual, 3)
+}
+
+func TestSimPlanRecords(t *testing.T) {
+	ctx := context.Background()
+	vc, cart := newSimVinoCart(t)
+	putCup(t, cart, 450, 0)
+
+	test.That(t, vc.FullDemo(ctx), test.ShouldBeNil)
+	vc.plans.flush()
+
+	files, err := PlanRecordFiles(vc.conf.PlanRecordDir)
+	test.That(t, err, test.ShouldBeNil)
+
+	bySource := map[string]*PlanRecord{}
+	for _, f := range files {
+		r, err := ReadPlanRecord(f)
+		test.That(t, err, test.ShouldBeNil)
+		test.That(t, r.RequestError, test.ShouldBeEmpty)
+		// some approaches are expected to fail
+		if r.Outcome == outcomeSuccess {
+			bySource[r.Source] = r
+		}
+	}
+	test.That(t, bySource[planSourceArmplanning], test.ShouldNotBeNil)
+	test.That(t, bySource[planSourceMotion], test.ShouldNotBeNil)
+	test.That(t, bySource[planSourceArmplanning].Trajectory, test.ShouldNotBeEmpty)
+	// just where the motion service started and ended
+	test.That(t, len(bySource[planSourceMotion].Trajectory), test.ShouldEqual, 2)
+
+	for _, r := range bySource {
+		res, err := Replan(ctx, vc.logger, r)
+		test.That(t, err, test.ShouldBeNil)
+		test.That(t, res.Err, test.ShouldBeNil)
+		test.That(t, res.OutcomeChanged(), test.ShouldBeFalse)
+		test.That(t, res.String(), test.ShouldContainSubstring, "now success")
+	}
+}
@@ -0,0 +1,115 @@
+package pour
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// positionKey is a stage/step in Positions that VinoCart moves through.
+type positionKey struct {
+	stage string
+	step  string
+	used  string // what it's for, shown by describe_positions
+
+	// requiredIf says if this config needs it, nil means always
+	requiredIf func(cfg *Config) bool
+}
+
+func (pk positionKey) String() string {
+	return pk.stage + "/" + pk.step
+}
+
+func (pk positionKey) required(cfg *Config) bool {
+	return pk.requiredIf == nil || pk.requiredIf(cfg)
+}
+
+func needsPickQuality(cfg *Config) bool {
+	return cfg.PickQualityService != ""
+}
+
+// positionKeys is every stage/step VinoCart uses, in the order a cycle gets to them.
+var positionKeys = []positionKey{
+	{stage: "reset", step: "left-holding-pre", used: "cup arm before putting a held cup down"},
+	{stage: "reset", step: "left-holding-post", used: "cup arm after putting a held cup down"},
+	{stage: "reset", step: "right-holding-pre", used: "bottle arm before putting the bottle back"},
+	{stage: "reset", step: "right-holding-post", used: "bottle arm after putting the bottle back"},
+	{stage: "touch", step: "prep", used: "both arms before looking for cups"},
+	{stage: "touch", step: "bad-pick-a", used: "cup arm before dropping a bad pick", requiredIf: needsPickQuality},
+	{stage: "touch", step: "bad-pick-b", used: "cup arm after dropping a bad pick", requiredIf: needsPickQuality},
+	{stage: "pour_prep", step: "prep-grab", used: "cup arm holding the cup up"},
+	{stage: "pour_prep", step: "right-grab", used: "bottle arm at the bottle"},
+	{stage: "pour_prep", step: "post-grab", used: "bottle arm after grabbing the bottle"},
+	{stage: "pour", step: "prep", used: "both arms ready to pour, the first set is planned from"},
+	{stage: "pour", step: "finish", used: "bottle arm after pouring"},
+	{stage: "put-back", step: "before-open", used: "cup arm before letting go of the cup"},
+	{stage: "put-back", step: "post-open", used: "both arms after letting go of the cup"},
+}
+
+func (cfg *Config) hasPosition(stage, step string) bool {
+	steps, ok := cfg.Positions[stage]
+	if !ok {
+		return false
+	}
+	return len(steps[step]) > 0
+}
+
+// validatePositions makes sure every stage/step this config needs is there,
+// so it fails at config time instead of halfway through a pour.
+func (cfg *Config) validatePositions() error {
+	missing := []string{}
+	for _, pk := range positionKeys {
+		if pk.required(cfg) && !cfg.hasPosition(pk.stage, pk.step) {
+			missing = append(missing, pk.String())
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("positions missing required stage/steps: %s", strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+// describePositions is what is configured in Positions, what VinoCart uses and what is missing.
+func (cfg *Config) describePositions() map[string]interface{} {
+	known := map[string]bool{}
+	positions := []map[string]interface{}{}
+	missing := []string{}
+
+	for _, pk := range positionKeys {
+		known[pk.String()] = true
+		required := pk.required(cfg)
+		configured := cfg.hasPosition(pk.stage, pk.step)
+		if required && !configured {
+			missing = append(missing, pk.String())
+		}
+
+		p := map[string]interface{}{
+			"stage":      pk.stage,
+			"step":       pk.step,
+			"used_for":   pk.used,
+			"required":   required,
+			"configured": configured,
+		}
+		if configured {
+			p["switches"] = cfg.Positions[pk.stage][pk.step]
+		}
+		positions = append(positions, p)
+	}
+
+	// steps nothing uses, only reachable through test_position
+	unused := []string{}
+	for stage, steps := range cfg.Positions {
+		for step := range steps {
+			if !known[stage+"/"+step] {
+				unused = append(unused, stage+"/"+step)
+			}
+		}
+	}
+	sort.Strings(unused)
+
+	return map[string]interface{}{
+		"positions": positions,
+		"missing":   missing,
+		"unused":    unused,
+	}
+}
@@ -0,0 +1,37 @@
+package pour
+
+import (
+	"testing"
+
+	"go.viam.com/test"
+)
+
+func TestValidatePositions(t *testing.T) {
+	cfg := &Config{Positions: map[string]ConfigStatePostions{}}
+	for _, pk := range positionKeys {
+		if pk.requiredIf != nil {
+			continue
+		}
+		if cfg.Positions[pk.stage] == nil {
+			cfg.Positions[pk.stage] = ConfigStatePostions{}
+		}
+		cfg.Positions[pk.stage][pk.step] = [][]string{{"x"}}
+	}
+	cfg.Positions["touch"]["wiggle"] = [][]string{{"y"}}
+	test.That(t, cfg.validatePositions(), test.ShouldBeNil)
+
+	cfg.PickQualityService = "pq"
+	err := cfg.validatePositions()
+	test.That(t, err, test.ShouldNotBeNil)
+	test.That(t, err.Error(), test.ShouldContainSubstring, "touch/bad-pick-a, touch/bad-pick-b")
+
+	cfg.PickQualityService = ""
+	cfg.Positions["pour"]["prep"] = [][]string{}
+	err = cfg.validatePositions()
+	test.That(t, err, test.ShouldNotBeNil)
+	test.That(t, err.Error(), test.ShouldContainSubstring, "pour/prep")
+
+	d := cfg.describePositions()
+	test.That(t, d["missing"], test.ShouldResemble, []string{"pour/prep"})
+	test.That(t, d["unused"], test.ShouldResemble, []string{"touch/wiggle"})
+}
@@ -16,9 +16,6 @@ import (
 	"go.viam.com/rdk/utils"
 )
 
-// Dataset to upload/train pour quality on
-const pourQualityDatasetID = "69ebb260ec6ccb3ae5466448"
-
 // Training label indicating a good pour
 const goodPour = TrainingLabel("good-pour")
 
@@ -79,7 +76,9 @@ func (pi *pourInsepctor) checkGoodPour(ctx context.Context, img image.Image) (bo
 //   - If the label is an `over-pour`, then the last two images will be labeled an `over-pour`,
 //     the two images prior will be labeled `good-pour`,
 //     and the two images further prior will be labeled `under-pour`
-func (pi *pourInsepctor) labelPour(ctx context.Context, pour time.Time, label TrainingLabel) error {
+//
+// Where they go, if anywhere, is up to the pour_quality stream in dc.
+func (pi *pourInsepctor) labelPour(ctx context.Context, pour time.Time, label TrainingLabel, dc DataCollection) error {
 	folderName := dirnameForPour(pour)
 	defer func() {
 		if err := cleanupImages(folderName); err != nil {
@@ -92,32 +91,55 @@ func (pi *pourInsepctor) labelPour(ctx context.Context, pour time.Time, label Tr
 		return nil
 	}
 
-	return pi.uploadTaggedImages(ctx, folderName, label)
+	s := dc.stream(streamPourQuality)
+	if !s.wanted() {
+		pi.logger.Infof("pour_quality stream doesn't want %s", folderName)
+		return nil
+	}
+
+	if dc.LocalOnly {
+		return pi.saveTaggedImages(folderName, label, filepath.Join(dc.localDir(), streamPourQuality))
+	}
+
+	if pi.dataClient == nil {
+		return fmt.Errorf("not connected to app, can't upload images")
+	}
+
+	return pi.uploadTaggedImages(ctx, folderName, label, s)
 }
 
-func (pi *pourInsepctor) uploadTaggedImages(ctx context.Context, folderPath string, label TrainingLabel) error {
+// saveTaggedImages copies the images uploadTaggedImages would upload into dir/<label>.
+func (pi *pourInsepctor) saveTaggedImages(folderPath string, label TrainingLabel, dir string) error {
+	pourTime := filepath.Base(folderPath)
+	return pi.forTaggedImages(folderPath, label, func(path string, l TrainingLabel) error {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		return writeLocal(filepath.Join(dir, string(l)), pourTime+"-"+filepath.Base(path), data)
+	})
+}
+
+func (pi *pourInsepctor) uploadTaggedImages(ctx context.Context, folderPath string, label TrainingLabel, s DataStream) error {
 	pid := os.Getenv(utils.MachinePartIDEnvVar)
 	if pid == "" {
 		return fmt.Errorf("%s not defined", utils.MachinePartIDEnvVar)
 	}
 	pourTime := filepath.Base(folderPath)
 
-	underPourOpts := &app.FileUploadOptions{
-		ComponentName: &pi.cameraName.Name,
-		Tags:          []string{string(underPour), pourTime},
-		DatasetIDs:    []string{pourQualityDatasetID},
-	}
-	goodPourOpts := &app.FileUploadOptions{
-		ComponentName: &pi.cameraName.Name,
-		Tags:          []string{string(goodPour), pourTime},
-		DatasetIDs:    []string{pourQualityDatasetID},
-	}
-	overPourOpts := &app.FileUploadOptions{
-		ComponentName: &pi.cameraName.Name,
-		Tags:          []string{string(overPour), pourTime},
-		DatasetIDs:    []string{pourQualityDatasetID},
-	}
+	return pi.forTaggedImages(folderPath, label, func(path string, l TrainingLabel) error {
+		opts := &app.FileUploadOptions{
+			ComponentName: &pi.cameraName.Name,
+			Tags:          append([]string{string(l), pourTime}, s.Tags...),
+			DatasetIDs:    []string{s.DatasetID},
+		}
+		_, err := pi.dataClient.FileUploadFromPath(ctx, pid, path, opts)
+		return err
+	})
+}
 
+// forTaggedImages calls f with each image from the pour worth keeping and what it shows.
+func (pi *pourInsepctor) forTaggedImages(folderPath string, label TrainingLabel, f func(path string, l TrainingLabel) error) error {
 	files, err := findFiles(folderPath)
 	if err != nil {
 		return err
@@ -139,7 +161,7 @@ func (pi *pourInsepctor) uploadTaggedImages(ctx context.Context, folderPath stri
 		endBoundary := max(0, numFiles-2)
 
 		for _, filepath := range files[endBoundary:] {
-			if _, err := pi.dataClient.FileUploadFromPath(ctx, pid, filepath, underPourOpts); err != nil {
+			if err := f(filepath, underPour); err != nil {
 				return err
 			}
 		}
@@ -151,13 +173,13 @@ func (pi *pourInsepctor) uploadTaggedImages(ctx context.Context, folderPath stri
 		oneFromEndBoundary := max(0, numFiles-1)
 
 		for _, path := range files[threeFromEndBoundary:oneFromEndBoundary] {
-			if _, err := pi.dataClient.FileUploadFromPath(ctx, pid, path, underPourOpts); err != nil {
+			if err := f(path, underPour); err != nil {
 				return err
 			}
 		}
 		// tag the last image as full
 		if numFiles > 0 {
-			if _, err := pi.dataClient.FileUploadFromPath(ctx, pid, files[numFiles-1], goodPourOpts); err != nil {
+			if err := f(files[numFiles-1], goodPour); err != nil {
 				return err
 			}
 		}
@@ -174,19 +196,19 @@ func (pi *pourInsepctor) uploadTaggedImages(ctx context.Context, folderPath stri
 		twoFromEndBoundary := max(0, numFiles-2)
 
 		for _, path := range files[sixFromEndBoundary:fourFromEndBoundary] {
-			if _, err := pi.dataClient.FileUploadFromPath(ctx, pid, path, underPourOpts); err != nil {
+			if err := f(path, underPour); err != nil {
 				return err
 			}
 		}
 
 		for _, path := range files[fourFromEndBoundary:twoFromEndBoundary] {
-			if _, err := pi.dataClient.FileUploadFromPath(ctx, pid, path, goodPourOpts); err != nil {
+			if err := f(path, goodPour); err != nil {
 				return err
 			}
 		}
 
 		for _, path := range files[twoFromEndBoundary:] {
-			if _, err := pi.dataClient.FileUploadFromPath(ctx, pid, path, overPourOpts); err != nil {
+			if err := f(path, overPour); err != nil {
 				return err
 			}
 		}
@@ -0,0 +1,213 @@
+package pour
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/golang/geo/r3"
+)
+
+// PourProfile is how a pour is done, tuned per bottle and glass.
+// Anything unset uses the default.
+type PourProfile struct {
+	// longest a pour can go for
+	TotalSeconds float64 `json:"total_seconds,omitempty"`
+	// how long to keep pouring once the glass starts filling
+	AfterMotionSeconds float64 `json:"after_motion_seconds,omitempty"`
+	// time between glass camera samples
+	SampleMs float64 `json:"sample_ms,omitempty"`
+
+	// how far above the cup top the bottle top starts
+	BottleGapMM float64 `json:"bottle_gap_mm,omitempty"`
+
+	// bottle tilt per step, in orientation vector OZ
+	TiltStep *float64 `json:"tilt_step,omitempty"`
+	// stop tilting at this OZ, -1 is straight down
+	TiltStop *float64 `json:"tilt_stop,omitempty"`
+	// how far the bottle top moves each step, in mm
+	Drift *r3.Vector `json:"drift_mm,omitempty"`
+
+	// a tilt step that moves the bottle arm further than this fails the plan
+	MaxStepL2 float64 `json:"max_step_l2,omitempty"`
+	// an alignment plan that moves the bottle arm further than this is replanned
+	MaxAlignL2 float64 `json:"max_align_l2,omitempty"`
+	AlignTries int     `json:"align_tries,omitempty"`
+}
+
+var defaultPourDrift = r3.Vector{X: .5, Y: -.5, Z: -1}
+
+func (pp *PourProfile) Validate(path string) error {
+	if pp.TotalSeconds < 0 {
+		return fmt.Errorf("%s.total_seconds can't be negative", path)
+	}
+	if pp.AfterMotionSeconds < 0 {
+		return fmt.Errorf("%s.after_motion_seconds can't be negative", path)
+	}
+	if pp.SampleMs < 0 {
+		return fmt.Errorf("%s.sample_ms can't be negative", path)
+	}
+	if pp.BottleGapMM < 0 {
+		return fmt.Errorf("%s.bottle_gap_mm can't be negative", path)
+	}
+	if pp.TiltStep != nil && *pp.TiltStep <= 0 {
+		return fmt.Errorf("%s.tilt_step must be positive, not %v", path, *pp.TiltStep)
+	}
+	// the bottle can't start past 1, so a stop there never tilts
+	if pp.TiltStop != nil && (*pp.TiltStop < -1 || *pp.TiltStop >= 1) {
+		return fmt.Errorf("%s.tilt_stop must be from -1 up to 1, not %v", path, *pp.TiltStop)
+	}
+	if pp.MaxStepL2 < 0 {
+		return fmt.Errorf("%s.max_step_l2 can't be negative", path)
+	}
+	if pp.MaxAlignL2 < 0 {
+		return fmt.Errorf("%s.max_align_l2 can't be negative", path)
+	}
+	if pp.AlignTries < 0 {
+		return fmt.Errorf("%s.align_tries can't be negative", path)
+	}
+	if pp.afterMotion() >= pp.totalTime() {
+		return fmt.Errorf("%s.after_motion_seconds (%v) must be less than total_seconds (%v)", path, pp.afterMotion(), pp.totalTime())
+	}
+	return nil
+}
+
+// with is pp with everything set in o replacing it.
+func (pp PourProfile) with(o *PourProfile) PourProfile {
+	if o == nil {
+		return pp
+	}
+	if o.TotalSeconds != 0 {
+		pp.TotalSeconds = o.TotalSeconds
+	}
+	if o.AfterMotionSeconds != 0 {
+		pp.AfterMotionSeconds = o.AfterMotionSeconds
+	}
+	if o.SampleMs != 0 {
+		pp.SampleMs = o.SampleMs
+	}
+	if o.BottleGapMM != 0 {
+		pp.BottleGapMM = o.BottleGapMM
+	}
+	if o.TiltStep != nil {
+		pp.TiltStep = o.TiltStep
+	}
+	if o.TiltStop != nil {
+		pp.TiltStop = o.TiltStop
+	}
+	if o.Drift != nil {
+		pp.Drift = o.Drift
+	}
+	if o.MaxStepL2 != 0 {
+		pp.MaxStepL2 = o.MaxStepL2
+	}
+	if o.MaxAlignL2 != 0 {
+		pp.MaxAlignL2 = o.MaxAlignL2
+	}
+	if o.AlignTries != 0 {
+		pp.AlignTries = o.AlignTries
+	}
+	return pp
+}
+
+func (pp *PourProfile) totalTime() time.Duration {
+	if pp.TotalSeconds > 0 {
+		return time.Duration(pp.TotalSeconds * float64(time.Second))
+	}
+	return 15 * time.Second
+}
+
+func (pp *PourProfile) afterMotion() time.Duration {
+	if pp.AfterMotionSeconds > 0 {
+		return time.Duration(pp.AfterMotionSeconds * float64(time.Second))
+	}
+	return time.Second
+}
+
+func (pp *PourProfile) sampleInterval() time.Duration {
+	if pp.SampleMs > 0 {
+		return time.Duration(pp.SampleMs * float64(time.Millisecond))
+	}
+	return 100 * time.Millisecond
+}
+
+func (pp *PourProfile) bottleGap() float64 {
+	if pp.BottleGapMM > 0 {
+		return pp.BottleGapMM
+	}
+	return 50
+}
+
+func (pp *PourProfile) tiltStep() float64 {
+	if pp.TiltStep != nil {
+		return *pp.TiltStep
+	}
+	return .05
+}
+
+func (pp *PourProfile) tiltStop() float64 {
+	if pp.TiltStop != nil {
+		return *pp.TiltStop
+	}
+	return -.5
+}
+
+func (pp *PourProfile) drift() r3.Vector {
+	if pp.Drift != nil {
+		return *pp.Drift
+	}
+	return defaultPourDrift
+}
+
+func (pp *PourProfile) maxStepL2() float64 {
+	if pp.MaxStepL2 > 0 {
+		return pp.MaxStepL2
+	}
+	return .2
+}
+
+func (pp *PourProfile) maxAlignL2() float64 {
+	if pp.MaxAlignL2 > 0 {
+		return pp.MaxAlignL2
+	}
+	return 1.3
+}
+
+func (pp *PourProfile) alignTries() int {
+	if pp.AlignTries > 0 {
+		return pp.AlignTries
+	}
+	return 5
+}
+
+func (c *Config) pourProfile() PourProfile {
+	if c.PourProfile != nil {
+		return *c.PourProfile
+	}
+	return PourProfile{}
+}
+
+// pourProfile is the configured profile with any overrides from a pour command.
+func (vc *VinoCart) pourProfile(cmd map[string]interface{}) (PourProfile, error) {
+	pp := vc.currentConf().pourProfile()
+
+	raw, ok := cmd["pour_profile"]
+	if !ok {
+		return pp, nil
+	}
+
+	data, err := json.Marshal(raw)
+	if err != nil {
+		return pp, err
+	}
+	o := &PourProfile{}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(o); err != nil {
+		return pp, fmt.Errorf("bad pour_profile: %w", err)
+	}
+
+	pp = pp.with(o)
+	return pp, pp.Validate("pour_profile")
+}
@@ -0,0 +1,54 @@
+package pour
+
+import (
+	"testing"
+	"time"
+
+	"github.com/golang/geo/r3"
+	"go.viam.com/test"
+)
+
+func TestPourProfile(t *testing.T) {
+	pp := PourProfile{}
+	test.That(t, pp.Validate("p"), test.ShouldBeNil)
+	test.That(t, pp.totalTime(), test.ShouldEqual, 15*time.Second)
+	test.That(t, pp.tiltStop(), test.ShouldEqual, -.5)
+	test.That(t, pp.drift(), test.ShouldResemble, r3.Vector{X: .5, Y: -.5, Z: -1})
+
+	vc := &VinoCart{conf: &Config{PourProfile: &PourProfile{TotalSeconds: 20, BottleGapMM: 40}}}
+
+	pp, err := vc.pourProfile(map[string]interface{}{"pour": true})
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, pp.totalTime(), test.ShouldEqual, 20*time.Second)
+
+	pp, err = vc.pourProfile(map[string]interface{}{
+		"pour_profile": map[string]interface{}{
+			"bottle_gap_mm": 60,
+			"tilt_stop":     0,
+			"drift_mm":      map[string]interface{}{"x": 1},
+		},
+	})
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, pp.totalTime(), test.ShouldEqual, 20*time.Second)
+	test.That(t, pp.bottleGap(), test.ShouldEqual, 60)
+	test.That(t, pp.tiltStop(), test.ShouldEqual, 0)
+	test.That(t, pp.drift(), test.ShouldResemble, r3.Vector{X: 1})
+
+	_, err = vc.pourProfile(map[string]interface{}{"pour_profile": map[string]interface{}{"align_tries": -1}})
+	test.That(t, err, test.ShouldNotBeNil)
+
+	// a tilt that never gets anywhere
+	for _, bad := range []map[string]interface{}{{"tilt_step": 0}, {"tilt_step": -.1}, {"tilt_stop": 1}} {
+		_, err = vc.pourProfile(map[string]interface{}{"pour_profile": bad})
+		test.That(t, err, test.ShouldNotBeNil)
+	}
+	pp, err = vc.pourProfile(map[string]interface{}{"pour_profile": map[string]interface{}{"tilt_step": .1}})
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, pp.tiltStep(), test.ShouldEqual, .1)
+
+	_, err = vc.pourProfile(map[string]interface{}{"pour_profile": map[string]interface{}{"after_motion_seconds": 30}})
+	test.That(t, err, test.ShouldNotBeNil)
+
+	_, err = vc.pourProfile(map[string]interface{}{"pour_profile": map[string]interface{}{"totl_seconds": 30}})
+	test.That(t, err, test.ShouldNotBeNil)
+}
@@ -0,0 +1,189 @@
+package pour
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/golang/geo/r3"
+)
+
+// Recipe is everything about one drink that differs from the rest of the config.
+// Anything unset uses the top level config.
+type Recipe struct {
+	BottleHeight    float64      `json:"bottle_height,omitempty"`
+	BottleTopOffset *r3.Vector   `json:"bottle_top_offset,omitempty"`
+	PourProfile     *PourProfile `json:"pour_profile,omitempty"`
+
+	// how to tell the glass is full
+	UseGlassFullnessMLModel  *bool   `json:"use_glass_fullness_model,omitempty"`
+	GlassFullnessService     string  `json:"glass_fullness_service,omitempty"`
+	GlassPourMotionThreshold float64 `json:"glass_pour_motion_threshold,omitempty"`
+}
+
+func (r *Recipe) Validate(path string) error {
+	if r.BottleHeight < 0 {
+		return fmt.Errorf("%s.bottle_height can't be negative", path)
+	}
+	if r.GlassPourMotionThreshold < 0 {
+		return fmt.Errorf("%s.glass_pour_motion_threshold can't be negative", path)
+	}
+	return nil
+}
+
+func (cfg *Config) validateRecipes() error {
+	if cfg.DefaultRecipe != "" {
+		if _, ok := cfg.Recipes[cfg.DefaultRecipe]; !ok {
+			return fmt.Errorf("default_recipe %q isn't in recipes %v", cfg.DefaultRecipe, cfg.recipeNames())
+		}
+	}
+	for name, r := range cfg.Recipes {
+		if name == "" {
+			return fmt.Errorf("recipes can't have an empty name")
+		}
+		path := "recipes." + name
+		if err := r.Validate(path); err != nil {
+			return err
+		}
+		rc, err := cfg.withRecipe(name)
+		if err != nil {
+			return err
+		}
+		if rc.PourProfile != nil {
+			if err := rc.PourProfile.Validate(path + ".pour_profile"); err != nil {
+				return err
+			}
+		}
+		if r.UseGlassFullnessMLModel != nil && rc.UseGlassFullnessMLModel && rc.GlassFullnessService == "" {
+			return fmt.Errorf("%s uses the glass fullness model but there's no glass_fullness_service", path)
+		}
+	}
+	return nil
+}
+
+func (cfg *Config) recipeNames() []string {
+	names := []string{}
+	for name := range cfg.Recipes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// fullnessServiceNames is every glass fullness service cfg or its recipes use.
+func (cfg *Config) fullnessServiceNames() []string {
+	names := []string{}
+	if cfg.GlassFullnessService != "" {
+		names = append(names, cfg.GlassFullnessService)
+	}
+	for _, name := range cfg.recipeNames() {
+		if s := cfg.Recipes[name].GlassFullnessService; s != "" {
+			names = append(names, s)
+		}
+	}
+	return names
+}
+
+// withRecipe is cfg as the named recipe sees it, "" is cfg itself.
+func (cfg *Config) withRecipe(name string) (*Config, error) {
+	if name == "" {
+		return cfg, nil
+	}
+	r, ok := cfg.Recipes[name]
+	if !ok {
+		return nil, fmt.Errorf("no recipe %q, have %v", name, cfg.recipeNames())
+	}
+
+	c := *cfg
+	if r.BottleHeight > 0 {
+		c.BottleHeight = r.BottleHeight
+	}
+	if r.BottleTopOffset != nil {
+		c.BottleTopOffset = r.BottleTopOffset
+	}
+	if r.PourProfile != nil {
+		pp := c.pourProfile().with(r.PourProfile)
+		c.PourProfile = &pp
+	}
+	if r.UseGlassFullnessMLModel != nil {
+		c.UseGlassFullnessMLModel = *r.UseGlassFullnessMLModel
+	}
+	if r.GlassFullnessService != "" {
+		c.GlassFullnessService = r.GlassFullnessService
+	}
+	if r.GlassPourMotionThreshold > 0 {
+		c.GlassPourMotionThreshold = r.GlassPourMotionThreshold
+	}
+	return &c, nil
+}
+
+// checkRecipe is an error if there's no such recipe.
+func (vc *VinoCart) checkRecipe(name string) error {
+	vc.confLock.RLock()
+	defer vc.confLock.RUnlock()
+	_, err := vc.baseConf.withRecipe(name)
+	return err
+}
+
+func (vc *VinoCart) activeRecipe() string {
+	vc.confLock.RLock()
+	defer vc.confLock.RUnlock()
+	return vc.recipe
+}
+
+// currentConf is conf for commands that can run during a cycle.
+func (vc *VinoCart) currentConf() *Config {
+	vc.confLock.RLock()
+	defer vc.confLock.RUnlock()
+	return vc.conf
+}
+
+// prepConf is called before a job or loop cycle moves the arms. It picks up any
+// new config and switches to recipe, or the default recipe if that's "".
+func (vc *VinoCart) prepConf(recipe string) {
+	vc.confLock.Lock()
+	defer vc.confLock.Unlock()
+
+	vc.applyPendingConfLocked()
+
+	if recipe == "" {
+		recipe = vc.baseConf.DefaultRecipe
+	}
+	if recipe != vc.recipe {
+		vc.recipe = recipe
+		vc.applyRecipeLocked()
+	}
+}
+
+// applyRecipeLocked makes conf the base config with the active recipe.
+func (vc *VinoCart) applyRecipeLocked() {
+	c, err := vc.baseConf.withRecipe(vc.recipe)
+	if err != nil {
+		vc.logger.Warnf("%v, using %q", err, vc.baseConf.DefaultRecipe)
+		vc.recipe = vc.baseConf.DefaultRecipe
+		c, err = vc.baseConf.withRecipe(vc.recipe)
+		if err != nil {
+			// default_recipe is validated, so this is just in case
+			vc.recipe = ""
+			c = vc.baseConf
+		}
+	}
+	vc.conf = c
+	vc.setupFrames()
+	vc.moveRecordLock.Lock()
+	vc.moveRecordFs = nil
+	vc.moveRecordLock.Unlock()
+	obstacles, err := c.obstacleGeometries()
+	if err != nil {
+		// validated, but a mesh file could have gone away since
+		vc.logger.Errorf("can't use obstacles: %v", err)
+	}
+	vc.obstacles = obstacles
+	svc, ok := vc.c.FullnessServices[c.GlassFullnessService]
+	if !ok {
+		svc = vc.c.GlassFullnessService
+	}
+	vc.pourInspector.visionService = svc
+	if vc.recipe != "" {
+		vc.logger.Infof("using recipe %q", vc.recipe)
+	}
+}
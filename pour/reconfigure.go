@@ -0,0 +1,131 @@
+package pour
+
+import (
+	"context"
+	"fmt"
+	"slices"
+
+	"go.viam.com/rdk/resource"
+)
+
+// dependencyNames is every resource cfg needs, sorted.
+func (cfg *Config) dependencyNames() ([]string, error) {
+	deps, optionals, err := cfg.Validate("")
+	if err != nil {
+		return nil, err
+	}
+	all := append(deps, optionals...)
+	slices.Sort(all)
+	return slices.Compact(all), nil
+}
+
+// needsRebuild says why going from old to cfg can't be done in place, "" if it can.
+func (cfg *Config) needsRebuild(old *Config) (string, error) {
+	newDeps, err := cfg.dependencyNames()
+	if err != nil {
+		return "", err
+	}
+	oldDeps, err := old.dependencyNames()
+	if err != nil {
+		return "", err
+	}
+	if !slices.Equal(newDeps, oldDeps) {
+		return fmt.Sprintf("dependencies changed from %v to %v", oldDeps, newDeps), nil
+	}
+	return "", nil
+}
+
+// Reconfigure applies a config that uses the same dependencies without rebuilding,
+// so the web server, clients and loop keep going. The new config takes effect
+// between cycles: now if the arms are idle, otherwise when the current job or
+// loop cycle finishes.
+func (vc *VinoCart) Reconfigure(ctx context.Context, deps resource.Dependencies, conf resource.Config) error {
+	newConf, err := resource.NativeConfig[*Config](conf)
+	if err != nil {
+		return err
+	}
+
+	vc.confLock.Lock()
+	defer vc.confLock.Unlock()
+
+	why, err := newConf.needsRebuild(vc.baseConf)
+	if err != nil {
+		return err
+	}
+	if why != "" {
+		vc.logger.Infof("rebuilding: %s", why)
+		return resource.NewMustRebuildError(conf.ResourceName())
+	}
+
+	// the web server isn't part of a cycle, so it can change right away
+	if err := vc.web.restart(ctx, newConf.Web); err != nil {
+		vc.logger.Warnf("error stopping old web server: %v", err)
+	}
+
+	// the switches are the same, but which stage uses which can change
+	positions, err := newConf.setupPositions(deps)
+	if err != nil {
+		return err
+	}
+
+	vc.pendingConf = newConf
+	vc.pendingPositions = positions
+	if vc.jobs.busy() == nil && !vc.loop.blocksManual() {
+		vc.applyPendingConfLocked()
+	} else {
+		vc.logger.Infof("new config will apply after the current cycle")
+	}
+
+	switch {
+	case newConf.Loop && !vc.loop.active():
+		if err := vc.StartLoop(); err != nil {
+			vc.logger.Warnf("config turned loop on but %v", err)
+		}
+	case !newConf.Loop && vc.loop.active():
+		if err := vc.stopLoopAfterCycle(); err != nil {
+			vc.logger.Warnf("can't stop loop: %v", err)
+		}
+	}
+	return nil
+}
+
+// applyPendingConf switches to the config from the last Reconfigure, if there is one,
+// keeping the active recipe if it's still there.
+// Only call it when nothing is moving the arms: before a job or between loop cycles.
+func (vc *VinoCart) applyPendingConf() {
+	vc.confLock.Lock()
+	defer vc.confLock.Unlock()
+	vc.applyPendingConfLocked()
+}
+
+func (vc *VinoCart) applyPendingConfLocked() {
+	if vc.pendingConf == nil {
+		return
+	}
+	old := vc.baseConf
+	vc.baseConf = vc.pendingConf
+	vc.pendingConf = nil
+	if vc.pendingPositions != nil {
+		vc.c.Positions = vc.pendingPositions
+		vc.pendingPositions = nil
+	}
+
+	vc.applyRecipeLocked()
+
+	if vc.baseConf.SpeedMultiplier != old.SpeedMultiplier {
+		err := vc.SetSpeedMultiplier(vc.baseConf.speedMultiplier())
+		if err != nil {
+			vc.logger.Errorf("can't set speed multiplier from config: %v", err)
+		}
+	}
+	vc.setSpeedControl(vc.baseConf.speedControl())
+	if vc.baseConf.JournalPath != old.JournalPath {
+		vc.journal.setPath(vc.baseConf.JournalPath)
+	}
+	vc.plans.configure(vc.baseConf.PlanRecordDir, vc.baseConf.PlanRecordLimit)
+	if vc.baseConf.TiltCachePath != old.TiltCachePath {
+		vc.tiltCache.setPath(vc.baseConf.TiltCachePath)
+	}
+
+	vc.logger.Infof("new config applied")
+}
@@ -0,0 +1,188 @@
+package pour
+
+import (
+	"context"
+	"time"
+
+	"go.viam.com/rdk/referenceframe"
+)
+
+// cycleStage is a step of FullDemo that can be resumed after.
+type cycleStage int
+
+const (
+	stageNone cycleStage = iota
+	stageTouch
+	stagePourPrep
+	stagePour
+	stagePutBack
+)
+
+func (s cycleStage) String() string {
+	switch s {
+	case stageTouch:
+		return "touch"
+	case stagePourPrep:
+		return "pour-prep"
+	case stagePour:
+		return "pour"
+	case stagePutBack:
+		return "put-back"
+	default:
+		return "none"
+	}
+}
+
+const (
+	loopRecoveryReset  = "reset"
+	loopRecoveryResume = "resume"
+)
+
+// if either arm moved more than this since the last stage finished, we don't
+// trust what we remember about the cycle
+const resumeMaxJointDrift = 0.1
+
+type stageRecord struct {
+	stage           cycleStage
+	at              time.Time
+	armJoints       []referenceframe.Input
+	bottleArmJoints []referenceframe.Input
+}
+
+func (vc *VinoCart) markStageDone(ctx context.Context, s cycleStage) {
+	r := stageRecord{stage: s, at: time.Now()}
+
+	var err error
+	r.armJoints, err = vc.c.Arm.JointPositions(ctx, nil)
+	if err != nil {
+		vc.logger.Warnf("markStageDone can't get arm joints: %v", err)
+	}
+	r.bottleArmJoints, err = vc.c.BottleArm.JointPositions(ctx, nil)
+	if err != nil {
+		vc.logger.Warnf("markStageDone can't get bottle arm joints: %v", err)
+	}
+
+	vc.stageLock.Lock()
+	defer vc.stageLock.Unlock()
+	vc.lastStage = r
+}
+
+// setPoured records whether the cup in the gripper has been poured into. It's
+// set once the bottle starts to tilt and only cleared by the next touch, however
+// the arms move in between.
+func (vc *VinoCart) setPoured(poured bool) {
+	vc.stageLock.Lock()
+	defer vc.stageLock.Unlock()
+	vc.poured = poured
+}
+
+func (vc *VinoCart) hasPoured() bool {
+	vc.stageLock.Lock()
+	defer vc.stageLock.Unlock()
+	return vc.poured
+}
+
+func (vc *VinoCart) lastCompletedStage() stageRecord {
+	vc.stageLock.Lock()
+	defer vc.stageLock.Unlock()
+	return vc.lastStage
+}
+
+// trustedLastStage returns the last completed stage, or stageNone if the arms
+// have moved since then (someone jogged them, or a stage failed mid motion).
+func (vc *VinoCart) trustedLastStage(ctx context.Context) (cycleStage, error) {
+	r := vc.lastCompletedStage()
+	if r.stage == stageNone || r.armJoints == nil || r.bottleArmJoints == nil {
+		return stageNone, nil
+	}
+
+	armJoints, err := vc.c.Arm.JointPositions(ctx, nil)
+	if err != nil {
+		return stageNone, err
+	}
+	bottleArmJoints, err := vc.c.BottleArm.JointPositions(ctx, nil)
+	if err != nil {
+		return stageNone, err
+	}
+
+	armDrift := referenceframe.InputsL2Distance(r.armJoints, armJoints)
+	bottleDrift := referenceframe.InputsL2Distance(r.bottleArmJoints, bottleArmJoints)
+	vc.logger.Infof("resume: last stage %v at %v, arm drift %0.3f bottle arm drift %0.3f", r.stage, r.at, armDrift, bottleDrift)
+
+	if armDrift > resumeMaxJointDrift || bottleDrift > resumeMaxJointDrift {
+		vc.logger.Warnf("resume: arms moved since %v finished, ignoring it", r.stage)
+		return stageNone, nil
+	}
+
+	return r.stage, nil
+}
+
+// Resume continues a cycle that failed part way through instead of starting over,
+// based on what the grippers are holding and the last stage that finished. A
+// stage only counts if the arms are still where it left them, otherwise they go
+// back through position switches before anything that assumes where they are.
+func (vc *VinoCart) Resume(ctx context.Context) (err error) {
+	rec := vc.startCycle("resume")
+	defer func() {
+		vc.endCycle(rec, err)
+	}()
+
+	cupHolding, err := vc.c.Gripper.IsHoldingSomething(ctx, nil)
+	if err != nil {
+		return err
+	}
+	bottleHolding, err := vc.c.BottleGripper.IsHoldingSomething(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	last, err := vc.trustedLastStage(ctx)
+	if err != nil {
+		return err
+	}
+
+	cup := cupHolding.IsHoldingSomething
+	bottle := bottleHolding.IsHoldingSomething
+	poured := vc.hasPoured()
+	vc.logger.Infof("resume: holding cup: %v bottle: %v poured: %v last stage: %v", cup, bottle, poured, last)
+
+	switch {
+	case poured && cup:
+		// already poured, just need to put the cup down
+		return vc.PutBack(ctx)
+	case poured && bottle:
+		// never pour into the same cup twice, it's on the table already
+		vc.logger.Infof("resume: poured cup already put down, putting the bottle back")
+		return vc.putDownBottle(ctx)
+	case poured:
+		vc.logger.Infof("resume: poured cup already put down, nothing to do")
+		return nil
+	case cup && bottle:
+		if last < stagePourPrep {
+			// back to where pour prep leaves the arms before lining up the bottle
+			err = vc.doAll(ctx, "pour_prep", "post-grab", 50)
+			if err != nil {
+				return err
+			}
+		}
+		err = vc.Pour(ctx)
+		if err != nil {
+			return err
+		}
+		return vc.PutBack(ctx)
+	case cup && last >= stageTouch:
+		err = vc.PourPrep(ctx)
+		if err != nil {
+			return err
+		}
+		err = vc.Pour(ctx)
+		if err != nil {
+			return err
+		}
+		return vc.PutBack(ctx)
+	default:
+		// nothing to go on, or a cup held somewhere we don't know. Touch resets
+		// first, which puts down anything still held
+		return vc.FullDemo(ctx)
+	}
+}
@@ -0,0 +1,334 @@
+package pour
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/golang/geo/r3"
+
+	"go.viam.com/rdk/components/sensor"
+	"go.viam.com/rdk/referenceframe"
+	"go.viam.com/rdk/resource"
+	"go.viam.com/rdk/services/generic"
+	"go.viam.com/rdk/services/vision"
+	"go.viam.com/rdk/spatialmath"
+)
+
+const jsonSchemaDraft = "https://json-schema.org/draft/2020-12/schema"
+
+// schemaModel is a registered model and the config it takes.
+type schemaModel struct {
+	api   resource.API
+	model resource.Model
+	conf  interface{}
+}
+
+// schemaModels is every model the module registers, keep in sync with cmd/module.
+var schemaModels = []schemaModel{
+	{generic.API, Model, Config{}},
+	{generic.API, VinoCartModel, Config{}},
+	{sensor.API, WeightModel, WeightConfig{}},
+	{sensor.API, WeightHardcodedModel, WeightHardcodedConfig{}},
+	{vision.API, VisionCupFinderModel, VisionCupFinderConfig{}},
+}
+
+// fieldDoc is what the schema says about a field beyond its type.
+type fieldDoc struct {
+	desc     string
+	required bool
+	def      interface{} // nil is no default, or one that depends on other fields
+}
+
+var (
+	zeroConfig      = &Config{}
+	zeroPourProfile = &PourProfile{}
+	zeroCupFinder   = &visionCupFinder{cfg: &VisionCupFinderConfig{}}
+	zeroDataColl    = &DataCollection{}
+)
+
+// fieldDocs has every field of every config struct, by json name. Defaults come from
+// the accessors so they can't drift from what the code does.
+var fieldDocs = map[reflect.Type]map[string]fieldDoc{
+	reflect.TypeOf(Config{}): {
+		"arm_name":                    {desc: "the cup arm", required: true},
+		"camera_name":                 {desc: "camera that looks at the table for cups", required: true},
+		"gripper_name":                {desc: "the cup arm's gripper", required: true},
+		"glass_pour_cam":              {desc: "camera that watches the glass fill"},
+		"glass_pour_motion_threshold": {desc: "how much motion in the glass cam means the glass is filling", def: zeroConfig.glassPourMotionThreshold()},
+		"cup_finder_service":          {desc: "vision service that finds the cups on the table"},
+		"Positions": {
+			desc:     "switches for saved arm positions by stage then step, each a list of sets run in order, the switches in a set run in parallel; describe_positions lists what's needed",
+			required: true,
+		},
+		"bottle_gripper": {desc: "the bottle arm's gripper"},
+		"bottle_arm":     {desc: "the bottle arm"},
+		"Handoff":        {desc: "if the cup arm can't reach a cup, pick it up with the bottle arm and hand it over", def: false},
+		"bottle_height":  {desc: "bottle height in mm", required: true},
+		"cup_height":     {desc: "cup height in mm", required: true},
+		"cup_width":      {desc: "cup width in mm, defaults to 60% of cup_height"},
+		"cup_grip_height_offset": {
+			desc: "how far up the cup the gripper grabs it, in mm", def: zeroConfig.cupGripHeightOffset(),
+		},
+		"bottle_top_offset": {desc: "where the bottle top is in the bottle gripper's frame in mm, the calibrate command measures it, defaults from bottle_height"},
+		"cup_top_offset":    {desc: "where the cup top is in the cup gripper's frame in mm, the calibrate command measures it", def: vectorMap(zeroConfig.cupTopOffset())},
+		"gripper_to_cup_center": {
+			desc: "how far past the cup center the gripper goes to grab it, in mm", def: zeroConfig.gripperToCupCenter(),
+		},
+		"pick_quality_service":     {desc: "vision classifier that checks the cup was picked up well, needs the touch/bad-pick-a and touch/bad-pick-b positions"},
+		"pour_glass_find_service":  {desc: "vision detector that finds the glass in the glass cam"},
+		"glass_fullness_service":   {desc: "vision classifier that says when the glass is full"},
+		"loop":                     {desc: "keep serving cups until stopped", def: false},
+		"use_glass_fullness_model": {desc: "stop pouring from glass_fullness_service instead of glass cam motion", def: false},
+		"loop_recovery":            {desc: "what loop mode does after a failed cycle", def: zeroConfig.loopRecovery()},
+		"multi_cup":                {desc: "serve every cup found instead of failing when there is more than one", def: false},
+		"cup_order":                {desc: "order to serve cups in", def: zeroConfig.cupOrder()},
+		"pour_profile":             {desc: "how to pour, unset fields use the defaults, each pour command can override it"},
+		"speeds": {
+			desc: "arm speeds by stage/step (\"pour_prep/prep-grab\") or named motion (" + strings.Join(namedSpeeds, ", ") + "), unset uses the built in speed",
+		},
+		"speed_multiplier": {desc: "scales every speed and acceleration, more than 0 and at most 1, can be changed at runtime", def: zeroConfig.speedMultiplier()},
+		"speed_control":    {desc: "how arm speeds are set, auto goes by the arm's kinematics model and uses generic if it's not an xArm or UR", def: zeroConfig.speedControl()},
+		"data_collection":  {desc: "which training images are collected and where they go"},
+		"web":              {desc: "where the vinoweb app is served"},
+		"recipes":          {desc: "named drinks, each overriding some of the top level config, picked with \"recipe\" on a command"},
+		"default_recipe":   {desc: "recipe used when a command doesn't pick one and in loop mode"},
+		"journal_path":     {desc: "where every pour cycle is recorded, defaults to the user cache dir"},
+		"tilt_cache_path":  {desc: "where planned pour tilts are kept between restarts, defaults to the user cache dir; clear_tilt_cache empties it"},
+		"plan_record_dir":  {desc: "every plan is recorded here, for replanning offline with cmd-tools replan, defaults to the user cache dir"},
+		"plan_record_limit": {
+			desc: "how many plan records to keep, -1 records none", def: defaultPlanRecordLimit,
+		},
+		"obstacles":   {desc: "fixed things around the cart, like the table, bottle holder and display, added to every motion request; world_state shows them"},
+		"cpu_threads": {desc: "most plans to run at once, defaults to half the cpus"},
+		"approach_timeout_seconds": {
+			desc: "longest to plan each cup approach, they're all planned at once and the best is used", def: zeroConfig.approachTimeout().Seconds(),
+		},
+		"trajectory_limits": {
+			desc: "checks on trajectories the arms run directly, by plan tag (touch-approach, pour-prep-grab, bottle-to-cup-align, pour-tilt, pour-return), \"default\" is added to the built in limits of tags not listed; a tag's entry replaces its built in limits, trajectories that fail are written to the plan dir",
+		},
+	},
+	reflect.TypeOf(TrajectoryLimits{}): {
+		"max_joint_delta":       {desc: "radians any one joint can turn between two steps, 0 is no limit"},
+		"max_step_l2":           {desc: "joint distance between two steps, 0 is no limit"},
+		"max_travel":            {desc: "joint distance over the whole trajectory, 0 is no limit"},
+		"no_wrist_flip":         {desc: "fail if the wrist goes through its singularity to the other side", def: false},
+		"max_path_deviation_mm": {desc: "mm the gripper can stray from a straight line between the start and end, 0 is no limit"},
+		"workspace":             {desc: "boxes the gripper has to stay in one of, none is no limit"},
+	},
+	reflect.TypeOf(WorkspaceBox{}): {
+		"name": {desc: "for humans"},
+		"min":  {desc: "lowest corner in world, in mm", required: true},
+		"max":  {desc: "highest corner in world, in mm", required: true},
+	},
+	reflect.TypeOf(Obstacle{}): {
+		"name":        {desc: "unique label for the geometry", required: true},
+		"frame":       {desc: "frame the pose is in", def: referenceframe.World},
+		"type":        {desc: "shape", required: true},
+		"translation": {desc: "center in frame, in mm"},
+		"orientation": {desc: "orientation in frame"},
+		"dims_mm":     {desc: "box size"},
+		"radius_mm":   {desc: "capsule radius"},
+		"length_mm":   {desc: "capsule length along its z axis, end to end"},
+		"mesh_file":   {desc: "path to a .ply mesh"},
+	},
+	reflect.TypeOf(spatialmath.OrientationVectorDegrees{}): {
+		"th": {desc: "rotation around the vector, in degrees"},
+		"x":  {desc: "vector x"},
+		"y":  {desc: "vector y"},
+		"z":  {desc: "vector z"},
+	},
+	reflect.TypeOf(PourProfile{}): {
+		"total_seconds":        {desc: "longest a pour can go for", def: zeroPourProfile.totalTime().Seconds()},
+		"after_motion_seconds": {desc: "how long to keep pouring once the glass starts filling", def: zeroPourProfile.afterMotion().Seconds()},
+		"sample_ms":            {desc: "time between glass camera samples", def: float64(zeroPourProfile.sampleInterval().Milliseconds())},
+		"bottle_gap_mm":        {desc: "how far above the cup top the bottle top starts", def: zeroPourProfile.bottleGap()},
+		"tilt_step":            {desc: "bottle tilt per step, in orientation vector OZ", def: zeroPourProfile.tiltStep()},
+		"tilt_stop":            {desc: "stop tilting at this OZ, -1 is straight down", def: zeroPourProfile.tiltStop()},
+		"drift_mm":             {desc: "how far the bottle top moves each step, in mm", def: vectorMap(zeroPourProfile.drift())},
+		"max_step_l2":          {desc: "a tilt step that moves the bottle arm further than this fails the plan", def: zeroPourProfile.maxStepL2()},
+		"max_align_l2":         {desc: "an alignment plan that moves the bottle arm further than this is replanned", def: zeroPourProfile.maxAlignL2()},
+		"align_tries":          {desc: "how many times to plan the alignment", def: zeroPourProfile.alignTries()},
+	},
+	reflect.TypeOf(SpeedProfile{}): {
+		"arm":        {desc: "cup arm speed"},
+		"bottle_arm": {desc: "bottle arm speed"},
+	},
+	reflect.TypeOf(ArmSpeed{}): {
+		"speed": {desc: "degrees per second"},
+		"accel": {desc: "degrees per second squared"},
+	},
+	reflect.TypeOf(DataCollection{}): {
+		"local_only":   {desc: "save images under local_dir instead of uploading them", def: false},
+		"local_dir":    {desc: "where local_only images go", def: zeroDataColl.localDir()},
+		"pick_quality": {desc: "cup in the gripper, after grabbing"},
+		"glass_cam":    {desc: "glass cam at the start of each pour"},
+		"pour_quality": {desc: "labeled pour images, from the under/good/over-pour commands"},
+	},
+	reflect.TypeOf(DataStream{}): {
+		"disabled":    {desc: "don't collect this stream", def: false},
+		"dataset_id":  {desc: "dataset to upload to, defaults to the stream's demo dataset"},
+		"tags":        {desc: "tags added to every image"},
+		"sample_rate": {desc: "fraction of images kept between 0 and 1, 0 means all of them"},
+	},
+	reflect.TypeOf(WebConfig{}): {
+		"disabled":      {desc: "don't serve the web app", def: false},
+		"host":          {desc: "address to bind to, all interfaces if unset"},
+		"port":          {desc: "port to listen on", def: defaultWebPort},
+		"tls_cert_file": {desc: "serve https with this cert, needs tls_key_file"},
+		"tls_key_file":  {desc: "key for tls_cert_file"},
+	},
+	reflect.TypeOf(Recipe{}): {
+		"bottle_height":               {desc: "replaces the top level bottle_height"},
+		"bottle_top_offset":           {desc: "replaces the top level bottle_top_offset"},
+		"pour_profile":                {desc: "merged over the top level pour_profile"},
+		"use_glass_fullness_model":    {desc: "replaces the top level use_glass_fullness_model"},
+		"glass_fullness_service":      {desc: "replaces the top level glass_fullness_service"},
+		"glass_pour_motion_threshold": {desc: "replaces the top level glass_pour_motion_threshold"},
+	},
+	reflect.TypeOf(WeightConfig{}): {
+		"Scale": {desc: "sensor whose readings are smoothed", required: true},
+	},
+	reflect.TypeOf(WeightHardcodedConfig{}): {
+		"Weight": {desc: "reading always returned, in kg"},
+	},
+	reflect.TypeOf(VisionCupFinderConfig{}): {
+		"input":      {desc: "vision service whose point cloud objects are checked for cups", required: true},
+		"height_mm":  {desc: "cup height in mm", required: true},
+		"width_mm":   {desc: "cup width in mm", required: true},
+		"good_delta": {desc: "how far off height and width an object can be and still be a cup, in mm", required: true},
+		"max_points": {desc: "objects are downsampled to this many points", def: zeroCupFinder.maxPoints()},
+	},
+}
+
+// allowed values for string fields that only take a few
+var fieldEnums = map[string][]string{
+	"loop_recovery": {loopRecoveryReset, loopRecoveryResume},
+	"cup_order":     {cupOrderDistance, cupOrderX, cupOrderY},
+	"speed_control": speedControlKinds,
+	"type":          {obstacleBox, obstacleCapsule, obstacleMesh},
+}
+
+// ConfigSchemas is a JSON Schema for every registered model's config, by model.
+func ConfigSchemas() (map[string]interface{}, error) {
+	all := map[string]interface{}{}
+	for _, m := range schemaModels {
+		s, err := schemaFor(reflect.TypeOf(m.conf))
+		if err != nil {
+			return nil, fmt.Errorf("%v: %w", m.model, err)
+		}
+		s["$schema"] = jsonSchemaDraft
+		s["title"] = m.model.String()
+		s["description"] = fmt.Sprintf("attributes for %v %v", m.api, m.model)
+		all[m.model.String()] = s
+	}
+	return all, nil
+}
+
+func schemaFor(t reflect.Type) (map[string]interface{}, error) {
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	if t == reflect.TypeOf(r3.Vector{}) {
+		props := map[string]interface{}{}
+		for _, k := range []string{"x", "y", "z"} {
+			props[k] = map[string]interface{}{"type": "number"}
+		}
+		return map[string]interface{}{"type": "object", "properties": props}, nil
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}, nil
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}, nil
+	case reflect.Int, reflect.Int32, reflect.Int64:
+		return map[string]interface{}{"type": "integer"}, nil
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}, nil
+	case reflect.Slice:
+		items, err := schemaFor(t.Elem())
+		if err != nil {
+			return nil, err
+		}
+		return map[string]interface{}{"type": "array", "items": items}, nil
+	case reflect.Map:
+		if t.Key().Kind() != reflect.String {
+			return nil, fmt.Errorf("%v: only string keyed maps can be in a config", t)
+		}
+		values, err := schemaFor(t.Elem())
+		if err != nil {
+			return nil, err
+		}
+		return map[string]interface{}{"type": "object", "additionalProperties": values}, nil
+	case reflect.Struct:
+		return structSchema(t)
+	default:
+		return nil, fmt.Errorf("%v: no schema for %v", t, t.Kind())
+	}
+}
+
+func structSchema(t reflect.Type) (map[string]interface{}, error) {
+	docs, ok := fieldDocs[t]
+	if !ok {
+		return nil, fmt.Errorf("%v isn't in fieldDocs", t)
+	}
+
+	props := map[string]interface{}{}
+	required := []string{}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		name := jsonName(f)
+		if name == "-" {
+			continue
+		}
+		doc, ok := docs[name]
+		if !ok {
+			return nil, fmt.Errorf("%v.%s has no fieldDoc", t, name)
+		}
+
+		s, err := schemaFor(f.Type)
+		if err != nil {
+			return nil, err
+		}
+		s["description"] = doc.desc
+		if doc.def != nil {
+			s["default"] = doc.def
+		}
+		if e, ok := fieldEnums[name]; ok && f.Type.Kind() == reflect.String {
+			s["enum"] = e
+		}
+		props[name] = s
+		if doc.required {
+			required = append(required, name)
+		}
+	}
+
+	for name := range docs {
+		if _, ok := props[name]; !ok {
+			return nil, fmt.Errorf("%v has a fieldDoc for %s, which isn't a field", t, name)
+		}
+	}
+
+	s := map[string]interface{}{"type": "object", "properties": props}
+	if len(required) > 0 {
+		sort.Strings(required)
+		s["required"] = required
+	}
+	return s, nil
+}
+
+// jsonName is the key encoding/json uses for f.
+func jsonName(f reflect.StructField) string {
+	tag := f.Tag.Get("json")
+	name, _, _ := strings.Cut(tag, ",")
+	if name == "" {
+		return f.Name
+	}
+	return name
+}
@@ -0,0 +1,33 @@
+package pour
+
+import (
+	"encoding/json"
+	"testing"
+
+	"go.viam.com/test"
+)
+
+func TestConfigSchemas(t *testing.T) {
+	all, err := ConfigSchemas()
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, len(all), test.ShouldEqual, len(schemaModels))
+
+	_, err = json.Marshal(all)
+	test.That(t, err, test.ShouldBeNil)
+
+	s := all[VinoCartModel.String()].(map[string]interface{})
+	test.That(t, s["required"], test.ShouldResemble, []string{"Positions", "arm_name", "bottle_height", "camera_name", "cup_height", "gripper_name"})
+
+	props := s["properties"].(map[string]interface{})
+	test.That(t, props["cup_grip_height_offset"].(map[string]interface{})["default"], test.ShouldEqual, 25.0)
+	test.That(t, props["cup_order"].(map[string]interface{})["enum"], test.ShouldResemble, []string{"distance", "x", "y"})
+
+	pp := props["pour_profile"].(map[string]interface{})["properties"].(map[string]interface{})
+	test.That(t, pp["total_seconds"].(map[string]interface{})["default"], test.ShouldEqual, 15.0)
+
+	recipes := props["recipes"].(map[string]interface{})["additionalProperties"].(map[string]interface{})
+	test.That(t, recipes["properties"], test.ShouldContainKey, "bottle_height")
+
+	finder := all[VisionCupFinderModel.String()].(map[string]interface{})
+	test.That(t, finder["required"], test.ShouldContain, "input")
+}
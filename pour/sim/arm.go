@@ -0,0 +1,168 @@
+package sim
+
+import (
+	"context"
+	_ "embed"
+	"fmt"
+	"sync"
+
+	"go.viam.com/rdk/components/arm"
+	"go.viam.com/rdk/referenceframe"
+	"go.viam.com/rdk/resource"
+	"go.viam.com/rdk/spatialmath"
+)
+
+//go:embed data/xarm6.json
+var xarm6JSON []byte
+
+// XArm6Model is the kinematics of an xArm6, what the cart is built with.
+func XArm6Model(name string) (referenceframe.Model, error) {
+	return referenceframe.UnmarshalModelJSON(xarm6JSON, name)
+}
+
+// Arm jumps straight to whatever joints it is sent to. It has real
+// kinematics so frame system poses and planning work.
+type Arm struct {
+	arm.Arm // not implemented, panics if called
+
+	name  resource.Name
+	model referenceframe.Model
+
+	lock      sync.Mutex
+	joints    []referenceframe.Input
+	moves     int
+	stops     int
+	speed     float64
+	options   *arm.MoveOptions
+	moveError error
+}
+
+func NewArm(name string, model referenceframe.Model, joints []referenceframe.Input) *Arm {
+	return &Arm{
+		name:   arm.Named(name),
+		model:  model,
+		joints: append([]referenceframe.Input{}, joints...),
+	}
+}
+
+func (a *Arm) Name() resource.Name {
+	return a.name
+}
+
+// SetMoveError makes every move fail with err until set back to nil.
+func (a *Arm) SetMoveError(err error) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	a.moveError = err
+}
+
+// Moves is how many moves have finished.
+func (a *Arm) Moves() int {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	return a.moves
+}
+
+// Stops is how many times Stop was called.
+func (a *Arm) Stops() int {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	return a.stops
+}
+
+// Speed is the last speed set with the xArm set_speed DoCommand.
+func (a *Arm) Speed() float64 {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	return a.speed
+}
+
+// MoveOptions is what the last move was sent, nil if it had none.
+func (a *Arm) MoveOptions() *arm.MoveOptions {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	return a.options
+}
+
+func (a *Arm) JointPositions(ctx context.Context, extra map[string]interface{}) ([]referenceframe.Input, error) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	return append([]referenceframe.Input{}, a.joints...), nil
+}
+
+func (a *Arm) CurrentInputs(ctx context.Context) ([]referenceframe.Input, error) {
+	return a.JointPositions(ctx, nil)
+}
+
+func (a *Arm) MoveToJointPositions(ctx context.Context, positions []referenceframe.Input, extra map[string]interface{}) error {
+	return a.MoveThroughJointPositions(ctx, [][]referenceframe.Input{positions}, nil, extra)
+}
+
+func (a *Arm) MoveThroughJointPositions(ctx context.Context, positions [][]referenceframe.Input, options *arm.MoveOptions, extra map[string]interface{}) error {
+	if len(positions) == 0 {
+		return nil
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	last := positions[len(positions)-1]
+	if len(last) != len(a.model.DoF()) {
+		return fmt.Errorf("%s wants %d joints, got %d", a.name.ShortName(), len(a.model.DoF()), len(last))
+	}
+
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	if a.moveError != nil {
+		return a.moveError
+	}
+	a.joints = append([]referenceframe.Input{}, last...)
+	a.options = options
+	a.moves++
+	return nil
+}
+
+func (a *Arm) GoToInputs(ctx context.Context, inputSteps ...[]referenceframe.Input) error {
+	return a.MoveThroughJointPositions(ctx, inputSteps, nil, nil)
+}
+
+func (a *Arm) EndPosition(ctx context.Context, extra map[string]interface{}) (spatialmath.Pose, error) {
+	joints, err := a.JointPositions(ctx, extra)
+	if err != nil {
+		return nil, err
+	}
+	return a.model.Transform(joints)
+}
+
+func (a *Arm) Kinematics(ctx context.Context) (referenceframe.Model, error) {
+	return a.model, nil
+}
+
+func (a *Arm) Geometries(ctx context.Context, extra map[string]interface{}) ([]spatialmath.Geometry, error) {
+	return nil, nil
+}
+
+func (a *Arm) Stop(ctx context.Context, extra map[string]interface{}) error {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	a.stops++
+	return nil
+}
+
+func (a *Arm) IsMoving(ctx context.Context) (bool, error) {
+	return false, nil
+}
+
+// DoCommand takes the xArm set_speed and set_acceleration commands.
+func (a *Arm) DoCommand(ctx context.Context, cmd map[string]interface{}) (map[string]interface{}, error) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	if s, ok := cmd["set_speed"].(float64); ok {
+		a.speed = s
+	}
+	return map[string]interface{}{}, nil
+}
+
+func (a *Arm) Close(ctx context.Context) error {
+	return nil
+}
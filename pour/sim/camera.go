@@ -0,0 +1,69 @@
+package sim
+
+import (
+	"context"
+	"fmt"
+	"image"
+	"sync"
+	"time"
+
+	"go.viam.com/rdk/components/camera"
+	"go.viam.com/rdk/data"
+	"go.viam.com/rdk/resource"
+	rutils "go.viam.com/rdk/utils"
+)
+
+// Camera plays back a list of images, one per Images call, then keeps
+// returning the last one.
+type Camera struct {
+	camera.Camera // not implemented, panics if called
+
+	name resource.Name
+
+	lock   sync.Mutex
+	images []image.Image
+	next   int
+}
+
+func NewCamera(name string, images ...image.Image) *Camera {
+	return &Camera{name: camera.Named(name), images: images}
+}
+
+func (c *Camera) Name() resource.Name {
+	return c.name
+}
+
+// SetImages starts playing back images from the beginning.
+func (c *Camera) SetImages(images ...image.Image) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.images = images
+	c.next = 0
+}
+
+func (c *Camera) Images(ctx context.Context, filterSourceNames []string, extra map[string]interface{}) ([]camera.NamedImage, resource.ResponseMetadata, error) {
+	c.lock.Lock()
+	if len(c.images) == 0 {
+		c.lock.Unlock()
+		return nil, resource.ResponseMetadata{}, fmt.Errorf("camera %s has no images", c.name.ShortName())
+	}
+	img := c.images[c.next]
+	if c.next < len(c.images)-1 {
+		c.next++
+	}
+	c.lock.Unlock()
+
+	ni, err := camera.NamedImageFromImage(img, "color", rutils.MimeTypePNG, data.Annotations{})
+	if err != nil {
+		return nil, resource.ResponseMetadata{}, err
+	}
+	return []camera.NamedImage{ni}, resource.ResponseMetadata{CapturedAt: time.Now()}, nil
+}
+
+func (c *Camera) DoCommand(ctx context.Context, cmd map[string]interface{}) (map[string]interface{}, error) {
+	return map[string]interface{}{}, nil
+}
+
+func (c *Camera) Close(ctx context.Context) error {
+	return nil
+}
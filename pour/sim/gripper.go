@@ -0,0 +1,121 @@
+package sim
+
+import (
+	"context"
+	"sync"
+
+	"go.viam.com/rdk/components/gripper"
+	"go.viam.com/rdk/referenceframe"
+	"go.viam.com/rdk/resource"
+	"go.viam.com/rdk/spatialmath"
+)
+
+// Gripper remembers whether it is holding something. Grab picks up whatever
+// is there, which is something unless SetEmpty says otherwise.
+type Gripper struct {
+	gripper.Gripper // not implemented, panics if called
+
+	name       resource.Name
+	model      referenceframe.Model
+	geometries []spatialmath.Geometry
+
+	lock    sync.Mutex
+	holding bool
+	empty   bool
+	grabs   int
+}
+
+func NewGripper(name string, geometries ...spatialmath.Geometry) (*Gripper, error) {
+	model, err := gripper.MakeModel(name, geometries)
+	if err != nil {
+		return nil, err
+	}
+	return &Gripper{
+		name:       gripper.Named(name),
+		model:      model,
+		geometries: geometries,
+	}, nil
+}
+
+func (g *Gripper) Name() resource.Name {
+	return g.name
+}
+
+// SetEmpty makes Grab close on nothing.
+func (g *Gripper) SetEmpty(empty bool) {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+	g.empty = empty
+}
+
+// SetHolding puts something in the gripper, or takes it away.
+func (g *Gripper) SetHolding(holding bool) {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+	g.holding = holding
+}
+
+func (g *Gripper) Holding() bool {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+	return g.holding
+}
+
+func (g *Gripper) Grabs() int {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+	return g.grabs
+}
+
+func (g *Gripper) Grab(ctx context.Context, extra map[string]interface{}) (bool, error) {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+	g.grabs++
+	g.holding = !g.empty
+	return g.holding, nil
+}
+
+func (g *Gripper) Open(ctx context.Context, extra map[string]interface{}) error {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+	g.holding = false
+	return nil
+}
+
+func (g *Gripper) IsHoldingSomething(ctx context.Context, extra map[string]interface{}) (gripper.HoldingStatus, error) {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+	return gripper.HoldingStatus{IsHoldingSomething: g.holding}, nil
+}
+
+func (g *Gripper) Kinematics(ctx context.Context) (referenceframe.Model, error) {
+	return g.model, nil
+}
+
+func (g *Gripper) Geometries(ctx context.Context, extra map[string]interface{}) ([]spatialmath.Geometry, error) {
+	return g.geometries, nil
+}
+
+func (g *Gripper) CurrentInputs(ctx context.Context) ([]referenceframe.Input, error) {
+	return []referenceframe.Input{}, nil
+}
+
+func (g *Gripper) GoToInputs(ctx context.Context, inputs ...[]referenceframe.Input) error {
+	return nil
+}
+
+func (g *Gripper) Stop(ctx context.Context, extra map[string]interface{}) error {
+	return nil
+}
+
+func (g *Gripper) IsMoving(ctx context.Context) (bool, error) {
+	return false, nil
+}
+
+func (g *Gripper) DoCommand(ctx context.Context, cmd map[string]interface{}) (map[string]interface{}, error) {
+	return map[string]interface{}{}, nil
+}
+
+func (g *Gripper) Close(ctx context.Context) error {
+	return nil
+}
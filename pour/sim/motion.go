@@ -0,0 +1,242 @@
+package sim
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"go.viam.com/rdk/logging"
+	"go.viam.com/rdk/motionplan/armplanning"
+	"go.viam.com/rdk/referenceframe"
+	"go.viam.com/rdk/resource"
+	"go.viam.com/rdk/robot/framesystem"
+	"go.viam.com/rdk/services/motion"
+	"go.viam.com/rdk/spatialmath"
+)
+
+// FrameSystem is the frame system service for the simulated arms and grippers.
+type FrameSystem struct {
+	framesystem.Service // not implemented, panics if called
+
+	lock   sync.Mutex
+	parts  []*referenceframe.FrameSystemPart
+	arms   map[string]*Arm
+	others []string // frames without inputs
+}
+
+func NewFrameSystem() *FrameSystem {
+	return &FrameSystem{arms: map[string]*Arm{}}
+}
+
+func (f *FrameSystem) Name() resource.Name {
+	return framesystem.PublicServiceName
+}
+
+// AddArm puts a at pose in parent.
+func (f *FrameSystem) AddArm(a *Arm, parent string, pose spatialmath.Pose) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	name := a.Name().ShortName()
+	f.parts = append(f.parts, &referenceframe.FrameSystemPart{
+		FrameConfig: referenceframe.NewLinkInFrame(parent, pose, name, nil),
+		ModelFrame:  a.model,
+	})
+	f.arms[name] = a
+}
+
+// AddGripper puts g at pose in parent, usually the end of an arm.
+func (f *FrameSystem) AddGripper(g *Gripper, parent string, pose spatialmath.Pose) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	name := g.Name().ShortName()
+	f.parts = append(f.parts, &referenceframe.FrameSystemPart{
+		FrameConfig: referenceframe.NewLinkInFrame(parent, pose, name, nil),
+		ModelFrame:  g.model,
+	})
+	f.others = append(f.others, name)
+}
+
+func (f *FrameSystem) arm(name string) (*Arm, bool) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	a, ok := f.arms[name]
+	return a, ok
+}
+
+func (f *FrameSystem) FrameSystemConfig(ctx context.Context) (*framesystem.Config, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	return &framesystem.Config{Parts: append([]*referenceframe.FrameSystemPart{}, f.parts...)}, nil
+}
+
+// frameSystem is the whole cart plus transforms.
+func (f *FrameSystem) frameSystem(transforms []*referenceframe.LinkInFrame) (*referenceframe.FrameSystem, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	return referenceframe.NewFrameSystem("sim", f.parts, transforms)
+}
+
+// inputs is where every arm is now.
+func (f *FrameSystem) inputs(ctx context.Context) (referenceframe.FrameSystemInputs, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	inputs := referenceframe.FrameSystemInputs{}
+	for name, a := range f.arms {
+		joints, err := a.JointPositions(ctx, nil)
+		if err != nil {
+			return nil, err
+		}
+		inputs[name] = joints
+	}
+	for _, name := range f.others {
+		inputs[name] = []referenceframe.Input{}
+	}
+	return inputs, nil
+}
+
+func (f *FrameSystem) GetPose(
+	ctx context.Context,
+	componentName, destinationFrame string,
+	supplementalTransforms []*referenceframe.LinkInFrame,
+	extra map[string]interface{},
+) (*referenceframe.PoseInFrame, error) {
+	fs, err := f.frameSystem(supplementalTransforms)
+	if err != nil {
+		return nil, err
+	}
+	inputs, err := f.inputs(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	tf, err := fs.Transform(inputs, referenceframe.NewPoseInFrame(componentName, spatialmath.NewZeroPose()), destinationFrame)
+	if err != nil {
+		return nil, err
+	}
+	pif, ok := tf.(*referenceframe.PoseInFrame)
+	if !ok {
+		return nil, fmt.Errorf("transform of %s gave a %T", componentName, tf)
+	}
+	return pif, nil
+}
+
+func (f *FrameSystem) DoCommand(ctx context.Context, cmd map[string]interface{}) (map[string]interface{}, error) {
+	return map[string]interface{}{}, nil
+}
+
+func (f *FrameSystem) Close(ctx context.Context) error {
+	return nil
+}
+
+// Motion plans with armplanning over the whole cart, then jumps the arms to the end of the plan.
+type Motion struct {
+	motion.Service // not implemented, panics if called
+
+	name   resource.Name
+	fs     *FrameSystem
+	logger logging.Logger
+
+	lock     sync.Mutex
+	failTags map[string]error
+	moves    []string
+}
+
+func NewMotion(fs *FrameSystem, logger logging.Logger) *Motion {
+	return &Motion{
+		name:     motion.Named("builtin"),
+		fs:       fs,
+		logger:   logger,
+		failTags: map[string]error{},
+	}
+}
+
+func (m *Motion) Name() resource.Name {
+	return m.name
+}
+
+// FailPlanTag makes every Move with this plan_tag fail with err, nil clears it.
+func (m *Motion) FailPlanTag(tag string, err error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	if err == nil {
+		delete(m.failTags, tag)
+		return
+	}
+	m.failTags[tag] = err
+}
+
+// Moves is the plan_tag of every successful Move, in order.
+func (m *Motion) Moves() []string {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	return append([]string{}, m.moves...)
+}
+
+func (m *Motion) Move(ctx context.Context, req motion.MoveReq) (bool, error) {
+	tag, _ := req.Extra["plan_tag"].(string)
+
+	m.lock.Lock()
+	err := m.failTags[tag]
+	m.lock.Unlock()
+	if err != nil {
+		return false, err
+	}
+
+	fs, err := m.fs.frameSystem(nil)
+	if err != nil {
+		return false, err
+	}
+	inputs, err := m.fs.inputs(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	plan, _, err := armplanning.PlanMotion(ctx, m.logger, &armplanning.PlanRequest{
+		FrameSystem: fs,
+		Goals: []*armplanning.PlanState{
+			armplanning.NewPlanState(referenceframe.FrameSystemPoses{req.ComponentName: req.Destination}, nil),
+		},
+		StartState:  armplanning.NewPlanState(nil, inputs),
+		WorldState:  req.WorldState,
+		Constraints: req.Constraints,
+	})
+	if err != nil {
+		return false, err
+	}
+
+	traj := plan.Trajectory()
+	last := traj[len(traj)-1]
+	for name, joints := range last {
+		a, ok := m.fs.arm(name)
+		if !ok || len(joints) == 0 {
+			continue
+		}
+		err := a.MoveToJointPositions(ctx, joints, nil)
+		if err != nil {
+			return false, err
+		}
+	}
+
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.moves = append(m.moves, tag)
+	return true, nil
+}
+
+func (m *Motion) GetPose(
+	ctx context.Context,
+	componentName, destinationFrame string,
+	supplementalTransforms []*referenceframe.LinkInFrame,
+	extra map[string]interface{},
+) (*referenceframe.PoseInFrame, error) {
+	return m.fs.GetPose(ctx, componentName, destinationFrame, supplementalTransforms, extra)
+}
+
+func (m *Motion) DoCommand(ctx context.Context, cmd map[string]interface{}) (map[string]interface{}, error) {
+	return map[string]interface{}{}, nil
+}
+
+func (m *Motion) Close(ctx context.Context) error {
+	return nil
+}
@@ -0,0 +1,120 @@
+package sim
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+
+	toggleswitch "go.viam.com/rdk/components/switch"
+	"go.viam.com/rdk/referenceframe"
+	"go.viam.com/rdk/resource"
+)
+
+// Position is a saved arm position switch like vmodutils' arm-position-saver:
+// position 2 moves the arm to the saved joints, 1 saves where the arm is now.
+type Position struct {
+	toggleswitch.Switch // not implemented, panics if called
+
+	name resource.Name
+	arm  *Arm
+
+	lock   sync.Mutex
+	joints []referenceframe.Input
+	motion string
+	gotos  int
+}
+
+func NewPosition(name string, a *Arm, joints []referenceframe.Input) *Position {
+	return &Position{
+		name:   toggleswitch.Named(name),
+		arm:    a,
+		joints: append([]referenceframe.Input{}, joints...),
+	}
+}
+
+func (p *Position) Name() resource.Name {
+	return p.name
+}
+
+// GoTos is how many times the arm was sent here.
+func (p *Position) GoTos() int {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	return p.gotos
+}
+
+// SetMotion says the position plans with the motion service called name, "" for none.
+func (p *Position) SetMotion(name string) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	p.motion = name
+}
+
+func (p *Position) SetPosition(ctx context.Context, position uint32, extra map[string]interface{}) error {
+	switch position {
+	case 0:
+		return nil
+	case 1:
+		joints, err := p.arm.JointPositions(ctx, nil)
+		if err != nil {
+			return err
+		}
+		p.lock.Lock()
+		defer p.lock.Unlock()
+		p.joints = joints
+		return nil
+	case 2:
+		p.lock.Lock()
+		joints := p.joints
+		p.gotos++
+		p.lock.Unlock()
+		return p.arm.MoveToJointPositions(ctx, joints, nil)
+	default:
+		return fmt.Errorf("bad position: %d", position)
+	}
+}
+
+func (p *Position) GetPosition(ctx context.Context, extra map[string]interface{}) (uint32, error) {
+	return 0, nil
+}
+
+func (p *Position) GetNumberOfPositions(ctx context.Context, extra map[string]interface{}) (uint32, []string, error) {
+	return 3, []string{"idle", "update config", "go to"}, nil
+}
+
+// DoCommand answers cfg the way arm-position-saver does.
+func (p *Position) DoCommand(ctx context.Context, cmd map[string]interface{}) (map[string]interface{}, error) {
+	if cmd["cfg"] != true {
+		return nil, fmt.Errorf("unknown command %v", cmd)
+	}
+
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	joints := []interface{}{}
+	for _, j := range p.joints {
+		joints = append(joints, float64(j))
+	}
+
+	cfg := map[string]interface{}{
+		"arm":    p.arm.Name().ShortName(),
+		"joints": p.joints,
+	}
+	if p.motion != "" {
+		cfg["motion"] = p.motion
+	}
+	asJSON, err := json.Marshal(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	return map[string]interface{}{
+		"joints":  joints,
+		"as_json": string(asJSON),
+	}, nil
+}
+
+func (p *Position) Close(ctx context.Context) error {
+	return nil
+}
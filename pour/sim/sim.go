@@ -0,0 +1,140 @@
+// Package sim is an in-process stand-in for the pouring cart: two xArm6s with
+// grippers, cameras, vision services, switch positions and a motion service
+// that really plans. Moves are instant, so a whole pour cycle runs under go test.
+package sim
+
+import (
+	"github.com/golang/geo/r3"
+
+	"go.viam.com/rdk/logging"
+	"go.viam.com/rdk/referenceframe"
+	"go.viam.com/rdk/resource"
+	"go.viam.com/rdk/spatialmath"
+)
+
+// resource names on the simulated cart
+const (
+	CupArmName        = "left-arm"
+	CupGripperName    = "left-gripper"
+	BottleArmName     = "right-arm"
+	BottleGripperName = "right-gripper"
+	CameraName        = "cam"
+	GlassPourCamName  = "glass-cam"
+	CupFinderName     = "cup-finder"
+	GlassFinderName   = "glass-finder"
+)
+
+// where the bottle arm sits, the cup arm is at the world origin, both facing +x
+var bottleArmOffset = r3.Vector{Y: -600}
+
+// how far the grippers stick out past the arms
+const gripperLength = 150
+
+// Joints for saved positions that make sense with this layout, in radians.
+var (
+	// gripper pointing +x, 300mm up, over nothing
+	CupArmHome = []referenceframe.Input{0, -1.6656, 0.0797, -3.1416, -0.0151, 0}
+	// holding the cup up to be poured into
+	CupArmPour = []referenceframe.Input{-0.8244, -0.3035, -0.3047, -4.0631, 1.1724, 0.4725}
+	// holding the cup over the table, ready to put it down
+	CupArmPlace = []referenceframe.Input{0, -0.215, -0.2086, -3.1416, 1.1472, 0}
+
+	BottleArmHome = []referenceframe.Input{0, -1.6656, 0.0797, -3.1416, -0.0151, 0}
+	// bottle top just above the cup held at CupArmPour
+	BottleArmPour = []referenceframe.Input{1.1876, 0.4946, -0.8334, -1.9341, 1.4462, -0.7795}
+)
+
+// Cart is everything the pouring demo talks to.
+type Cart struct {
+	Arm           *Arm
+	Gripper       *Gripper
+	BottleArm     *Arm
+	BottleGripper *Gripper
+
+	Cam          *Camera
+	GlassPourCam *Camera
+
+	CupFinder   *Vision
+	GlassFinder *Vision
+
+	FrameSystem *FrameSystem
+	Motion      *Motion
+
+	Positions map[string]*Position
+}
+
+// NewCart has both arms at home, grippers open and nothing on the table.
+func NewCart(logger logging.Logger) (*Cart, error) {
+	c := &Cart{
+		Cam:          NewCamera(CameraName),
+		GlassPourCam: NewCamera(GlassPourCamName),
+		CupFinder:    NewVision(CupFinderName),
+		GlassFinder:  NewVision(GlassFinderName),
+		FrameSystem:  NewFrameSystem(),
+		Positions:    map[string]*Position{},
+	}
+	c.Motion = NewMotion(c.FrameSystem, logger)
+
+	cupModel, err := XArm6Model(CupArmName)
+	if err != nil {
+		return nil, err
+	}
+	bottleModel, err := XArm6Model(BottleArmName)
+	if err != nil {
+		return nil, err
+	}
+	c.Arm = NewArm(CupArmName, cupModel, CupArmHome)
+	c.BottleArm = NewArm(BottleArmName, bottleModel, BottleArmHome)
+
+	c.Gripper, err = newCartGripper(CupGripperName)
+	if err != nil {
+		return nil, err
+	}
+	c.BottleGripper, err = newCartGripper(BottleGripperName)
+	if err != nil {
+		return nil, err
+	}
+
+	gripperPose := spatialmath.NewPoseFromPoint(r3.Vector{Z: gripperLength})
+	c.FrameSystem.AddArm(c.Arm, referenceframe.World, spatialmath.NewZeroPose())
+	c.FrameSystem.AddGripper(c.Gripper, CupArmName, gripperPose)
+	c.FrameSystem.AddArm(c.BottleArm, referenceframe.World, spatialmath.NewPoseFromPoint(bottleArmOffset))
+	c.FrameSystem.AddGripper(c.BottleGripper, BottleArmName, gripperPose)
+
+	return c, nil
+}
+
+func newCartGripper(name string) (*Gripper, error) {
+	box, err := spatialmath.NewBox(
+		spatialmath.NewPoseFromPoint(r3.Vector{Z: -60}),
+		r3.Vector{X: 80, Y: 120, Z: 100},
+		name+"-box",
+	)
+	if err != nil {
+		return nil, err
+	}
+	return NewGripper(name, box)
+}
+
+// AddPosition saves a switch position for a that goes to joints.
+func (c *Cart) AddPosition(name string, a *Arm, joints []referenceframe.Input) *Position {
+	p := NewPosition(name, a, joints)
+	c.Positions[name] = p
+	return p
+}
+
+// Dependencies is the cart the way a module would get it.
+func (c *Cart) Dependencies() resource.Dependencies {
+	deps := resource.Dependencies{}
+	for _, r := range []resource.Resource{
+		c.Arm, c.Gripper, c.BottleArm, c.BottleGripper,
+		c.Cam, c.GlassPourCam, c.CupFinder, c.GlassFinder,
+		c.FrameSystem, c.Motion,
+	} {
+		deps[r.Name()] = r
+	}
+	for _, p := range c.Positions {
+		deps[p.Name()] = p
+	}
+	return deps
+}
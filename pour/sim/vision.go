@@ -0,0 +1,129 @@
+package sim
+
+import (
+	"context"
+	"image"
+	"math"
+	"sync"
+
+	"github.com/golang/geo/r3"
+
+	"go.viam.com/rdk/pointcloud"
+	"go.viam.com/rdk/resource"
+	"go.viam.com/rdk/services/vision"
+	viz "go.viam.com/rdk/vision"
+	"go.viam.com/rdk/vision/classification"
+	"go.viam.com/rdk/vision/objectdetection"
+)
+
+// Vision returns whatever objects, detections and classifications it was last given.
+// It stands in for the cup finder, the pour glass finder and the pick quality model.
+type Vision struct {
+	vision.Service // not implemented, panics if called
+
+	name resource.Name
+
+	lock            sync.Mutex
+	objects         []*viz.Object
+	detections      []objectdetection.Detection
+	classifications classification.Classifications
+	err             error
+}
+
+func NewVision(name string) *Vision {
+	return &Vision{name: vision.Named(name)}
+}
+
+func (v *Vision) Name() resource.Name {
+	return v.name
+}
+
+func (v *Vision) SetObjects(objects ...*viz.Object) {
+	v.lock.Lock()
+	defer v.lock.Unlock()
+	v.objects = objects
+}
+
+func (v *Vision) SetDetections(detections ...objectdetection.Detection) {
+	v.lock.Lock()
+	defer v.lock.Unlock()
+	v.detections = detections
+}
+
+func (v *Vision) SetClassifications(cs classification.Classifications) {
+	v.lock.Lock()
+	defer v.lock.Unlock()
+	v.classifications = cs
+}
+
+// SetError makes every call fail with err until set back to nil.
+func (v *Vision) SetError(err error) {
+	v.lock.Lock()
+	defer v.lock.Unlock()
+	v.err = err
+}
+
+func (v *Vision) GetObjectPointClouds(ctx context.Context, cameraName string, extra map[string]interface{}) ([]*viz.Object, error) {
+	v.lock.Lock()
+	defer v.lock.Unlock()
+	return v.objects, v.err
+}
+
+func (v *Vision) DetectionsFromCamera(ctx context.Context, cameraName string, extra map[string]interface{}) ([]objectdetection.Detection, error) {
+	v.lock.Lock()
+	defer v.lock.Unlock()
+	return v.detections, v.err
+}
+
+func (v *Vision) Classifications(ctx context.Context, img image.Image, n int, extra map[string]interface{}) (classification.Classifications, error) {
+	v.lock.Lock()
+	defer v.lock.Unlock()
+	return v.classifications, v.err
+}
+
+func (v *Vision) DoCommand(ctx context.Context, cmd map[string]interface{}) (map[string]interface{}, error) {
+	return map[string]interface{}{}, nil
+}
+
+func (v *Vision) Close(ctx context.Context) error {
+	return nil
+}
+
+// NewCup is the point cloud of a cup standing on the table (z = 0) with its
+// center at x, y, the way a cup finder would see it.
+func NewCup(x, y, height, width float64) (*viz.Object, error) {
+	pc := pointcloud.NewBasicEmpty()
+	for z := 0.0; z <= height; z += 5 {
+		for a := 0.0; a < 2*math.Pi; a += math.Pi / 16 {
+			p := r3.Vector{X: x + math.Cos(a)*width/2, Y: y + math.Sin(a)*width/2, Z: z}
+			if err := pc.Set(p, nil); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return viz.NewObject(pc)
+}
+
+type detection struct {
+	objectdetection.Detection // not implemented, panics if called
+
+	box   image.Rectangle
+	label string
+}
+
+// NewDetection is a detection of label inside box.
+func NewDetection(box image.Rectangle, label string) objectdetection.Detection {
+	return &detection{box: box, label: label}
+}
+
+func (d *detection) BoundingBox() *image.Rectangle {
+	return &d.box
+}
+
+func (d *detection) Score() float64 {
+	return 1
+}
+
+func (d *detection) Label() string {
+	return d.label
+}
@@ -0,0 +1,311 @@
+package pour
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+
+	"go.viam.com/rdk/components/arm"
+	toggleswitch "go.viam.com/rdk/components/switch"
+	"go.viam.com/rdk/referenceframe"
+	"go.viam.com/rdk/utils"
+)
+
+// speed_control values, how an arm is told how fast to go
+const (
+	speedControlAuto    = "auto"    // from the arm's kinematics model, generic if it's not an xArm or UR (default)
+	speedControlXarm    = "xarm"    // set_speed and set_acceleration DoCommand
+	speedControlUR      = "ur"      // set_vel and set_acc DoCommand
+	speedControlGeneric = "generic" // limits passed with every move
+)
+
+var speedControlKinds = []string{speedControlAuto, speedControlXarm, speedControlUR, speedControlGeneric}
+
+func validSpeedControl(kind string) error {
+	for _, k := range speedControlKinds {
+		if kind == k {
+			return nil
+		}
+	}
+	return fmt.Errorf("speed_control must be one of %v, not %q", speedControlKinds, kind)
+}
+
+func (cfg *Config) speedControl() string {
+	if cfg.SpeedControl != "" {
+		return cfg.SpeedControl
+	}
+	return speedControlAuto
+}
+
+// speedControl is how one arm's speed is set.
+type speedControl interface {
+	kind() string
+	set(ctx context.Context, as ArmSpeed) error
+	// moveOptions is for joint moves, nil if set already did it
+	moveOptions() *arm.MoveOptions
+	// extra is for motion service moves, nil if set already did it
+	extra() map[string]interface{}
+}
+
+// doCommandSpeed sets the speed on the arm with a driver specific DoCommand.
+type doCommandSpeed struct {
+	name     string
+	a        arm.Arm
+	speedKey string
+	accelKey string
+}
+
+func (dc *doCommandSpeed) kind() string {
+	return dc.name
+}
+
+func (dc *doCommandSpeed) set(ctx context.Context, as ArmSpeed) error {
+	_, err := dc.a.DoCommand(ctx, map[string]interface{}{
+		dc.speedKey: as.Speed,
+		dc.accelKey: as.Accel,
+	})
+	return err
+}
+
+func (dc *doCommandSpeed) moveOptions() *arm.MoveOptions {
+	return nil
+}
+
+func (dc *doCommandSpeed) extra() map[string]interface{} {
+	return nil
+}
+
+func newXarmSpeed(a arm.Arm) *doCommandSpeed {
+	return &doCommandSpeed{name: speedControlXarm, a: a, speedKey: "set_speed", accelKey: "set_acceleration"}
+}
+
+func newURSpeed(a arm.Arm) *doCommandSpeed {
+	return &doCommandSpeed{name: speedControlUR, a: a, speedKey: "set_vel", accelKey: "set_acc"}
+}
+
+// genericSpeed remembers the speed and hands it to each move, for arms without
+// a speed DoCommand.
+type genericSpeed struct {
+	lock sync.Mutex
+	cur  ArmSpeed
+}
+
+func (gs *genericSpeed) kind() string {
+	return speedControlGeneric
+}
+
+func (gs *genericSpeed) set(ctx context.Context, as ArmSpeed) error {
+	gs.lock.Lock()
+	defer gs.lock.Unlock()
+	gs.cur = as
+	return nil
+}
+
+func (gs *genericSpeed) get() ArmSpeed {
+	gs.lock.Lock()
+	defer gs.lock.Unlock()
+	return gs.cur
+}
+
+func (gs *genericSpeed) moveOptions() *arm.MoveOptions {
+	as := gs.get()
+	if as.Speed <= 0 && as.Accel <= 0 {
+		return nil
+	}
+	return &arm.MoveOptions{MaxVelRads: utils.DegToRad(as.Speed), MaxAccRads: utils.DegToRad(as.Accel)}
+}
+
+// extra uses the same names as the arm API's MoveOptions.
+func (gs *genericSpeed) extra() map[string]interface{} {
+	as := gs.get()
+	m := map[string]interface{}{}
+	if as.Speed > 0 {
+		m["max_vel_degs_per_sec"] = as.Speed
+	}
+	if as.Accel > 0 {
+		m["max_acc_degs_per_sec2"] = as.Accel
+	}
+	if len(m) == 0 {
+		return nil
+	}
+	return m
+}
+
+// speedControlForModel is the speed control for an arm whose kinematics model is
+// called name. The xArm and UR drivers name theirs after the arm model, drivers
+// that don't can't be trusted to reject a DoCommand they don't know.
+func speedControlForModel(name string) string {
+	name = strings.ToLower(name)
+	switch {
+	case strings.HasPrefix(name, "xarm"), strings.HasPrefix(name, "lite6"):
+		return speedControlXarm
+	case strings.HasPrefix(name, "ur"):
+		return speedControlUR
+	default:
+		return speedControlGeneric
+	}
+}
+
+// detectSpeedControl is the speed control for a's kinematics model.
+func detectSpeedControl(ctx context.Context, a arm.Arm) (string, error) {
+	model, err := a.Kinematics(ctx)
+	if err != nil {
+		return "", fmt.Errorf("can't detect speed control of %s: %w", a.Name().ShortName(), err)
+	}
+	return speedControlForModel(model.Name()), nil
+}
+
+// applySpeed sets a to as times the speed multiplier.
+func (vc *VinoCart) applySpeed(ctx context.Context, a arm.Arm, as ArmSpeed) error {
+	m := vc.getSpeedMultiplier()
+	as = ArmSpeed{Speed: as.Speed * m, Accel: as.Accel * m}
+
+	name := a.Name().ShortName()
+
+	vc.speedLock.Lock()
+	sc, ok := vc.speedControls[name]
+	kind := vc.speedControlKind
+	vc.speedLock.Unlock()
+	if ok {
+		return sc.set(ctx, as)
+	}
+
+	if kind == speedControlAuto {
+		detected, err := detectSpeedControl(ctx, a)
+		if err != nil {
+			return err
+		}
+		vc.logger.Infof("%s speed control is %s", name, detected)
+		kind = detected
+	}
+
+	switch kind {
+	case speedControlXarm:
+		sc = newXarmSpeed(a)
+	case speedControlUR:
+		sc = newURSpeed(a)
+	default:
+		sc = &genericSpeed{}
+	}
+
+	vc.storeSpeedControl(name, sc)
+	return sc.set(ctx, as)
+}
+
+func (vc *VinoCart) storeSpeedControl(name string, sc speedControl) {
+	vc.speedLock.Lock()
+	defer vc.speedLock.Unlock()
+	vc.speedControls[name] = sc
+}
+
+// armSpeedControl is how a's speed is set, nil until its speed has been set once.
+func (vc *VinoCart) armSpeedControl(a arm.Arm) speedControl {
+	vc.speedLock.Lock()
+	defer vc.speedLock.Unlock()
+	return vc.speedControls[a.Name().ShortName()]
+}
+
+// setSpeedControl changes how speeds are set, arms are detected again on their next speed.
+func (vc *VinoCart) setSpeedControl(kind string) {
+	vc.speedLock.Lock()
+	defer vc.speedLock.Unlock()
+	if kind == vc.speedControlKind {
+		return
+	}
+	vc.logger.Infof("speed control %s -> %s", vc.speedControlKind, kind)
+	vc.speedControlKind = kind
+	vc.speedControls = map[string]speedControl{}
+}
+
+// speedControlStatus is each arm's speed control, for status.
+func (vc *VinoCart) speedControlStatus() map[string]interface{} {
+	vc.speedLock.Lock()
+	defer vc.speedLock.Unlock()
+	m := map[string]interface{}{"config": vc.speedControlKind}
+	for name, sc := range vc.speedControls {
+		m[name] = sc.kind()
+	}
+	return m
+}
+
+// moveExtra is the motion service extra for moving a, with the plan tag.
+func (vc *VinoCart) moveExtra(a arm.Arm, tag string) map[string]interface{} {
+	extra := planTagExtra(tag)
+	if sc := vc.armSpeedControl(a); sc != nil {
+		for k, v := range sc.extra() {
+			extra[k] = v
+		}
+	}
+	return extra
+}
+
+// armNamed is the cup or bottle arm called name, nil if it's neither.
+func (vc *VinoCart) armNamed(name string) arm.Arm {
+	for _, a := range []arm.Arm{vc.c.Arm, vc.c.BottleArm} {
+		if a != nil && a.Name().ShortName() == name {
+			return a
+		}
+	}
+	return nil
+}
+
+// setPosition moves to position p. Position switches ignore extra, so an arm
+// whose speed goes with each move is sent to the saved joints directly, unless
+// the switch plans with a motion service, which only it knows how to do.
+func (vc *VinoCart) setPosition(ctx context.Context, p toggleswitch.Switch) error {
+	perMove := false
+	for _, a := range []arm.Arm{vc.c.Arm, vc.c.BottleArm} {
+		if a == nil {
+			continue
+		}
+		if sc := vc.armSpeedControl(a); sc != nil && sc.moveOptions() != nil {
+			perMove = true
+		}
+	}
+	if !perMove {
+		return p.SetPosition(ctx, 2, nil)
+	}
+
+	cfg, err := switchConfig(ctx, p)
+	if err != nil {
+		return err
+	}
+	a := vc.armNamed(cfg.Arm)
+	if a == nil {
+		return p.SetPosition(ctx, 2, nil)
+	}
+	sc := vc.armSpeedControl(a)
+	if sc == nil || sc.moveOptions() == nil {
+		return p.SetPosition(ctx, 2, nil)
+	}
+	if len(cfg.Joints) == 0 || cfg.Motion != "" {
+		vc.logger.Warnf("position %v moves %s itself, at full speed", p.Name(), cfg.Arm)
+		return p.SetPosition(ctx, 2, nil)
+	}
+
+	joints := []referenceframe.Input{}
+	for _, j := range cfg.Joints {
+		joints = append(joints, referenceframe.Input(j))
+	}
+	return vc.moveArm(ctx, a, "position-"+p.Name().ShortName(), joints)
+}
+
+// moveArm moves a through positions at its current speed, if the trajectory
+// passes the limits for tag.
+func (vc *VinoCart) moveArm(ctx context.Context, a arm.Arm, tag string, positions ...[]referenceframe.Input) error {
+	err := vc.validateMove(ctx, a, tag, positions)
+	if err != nil {
+		return err
+	}
+
+	var opts *arm.MoveOptions
+	var extra map[string]interface{}
+	if sc := vc.armSpeedControl(a); sc != nil {
+		opts, extra = sc.moveOptions(), sc.extra()
+	}
+	if opts == nil && len(positions) == 1 {
+		return a.MoveToJointPositions(ctx, positions[0], extra)
+	}
+	return a.MoveThroughJointPositions(ctx, positions, opts, extra)
+}
@@ -0,0 +1,165 @@
+package pour
+
+import (
+	"context"
+	"fmt"
+)
+
+// speeds keys for motions that aren't a stage/step in Positions
+const (
+	speedDefault     = "default"      // where arms are left between motions
+	speedTouchPickup = "touch-pickup" // cup arm going down to the cup
+	speedPourTilt    = "pour-tilt"    // bottle arm tilting to pour
+	speedPourReturn  = "pour-return"  // bottle arm tilting back
+)
+
+var namedSpeeds = []string{speedDefault, speedTouchPickup, speedPourTilt, speedPourReturn}
+
+// ArmSpeed is in degrees per second and degrees per second squared, unset uses the default.
+type ArmSpeed struct {
+	Speed float64 `json:"speed,omitempty"`
+	Accel float64 `json:"accel,omitempty"`
+}
+
+func (as ArmSpeed) or(def ArmSpeed) ArmSpeed {
+	if as.Speed <= 0 {
+		as.Speed = def.Speed
+	}
+	if as.Accel <= 0 {
+		as.Accel = def.Accel
+	}
+	return as
+}
+
+func (as ArmSpeed) validate(path string) error {
+	if as.Speed < 0 {
+		return fmt.Errorf("%s.speed can't be negative", path)
+	}
+	if as.Accel < 0 {
+		return fmt.Errorf("%s.accel can't be negative", path)
+	}
+	return nil
+}
+
+// SpeedProfile is how fast each arm moves for one stage/step or named motion.
+type SpeedProfile struct {
+	Arm       ArmSpeed `json:"arm"`
+	BottleArm ArmSpeed `json:"bottle_arm"`
+}
+
+func (cfg *Config) validateSpeeds() error {
+	if cfg.SpeedControl != "" {
+		if err := validSpeedControl(cfg.SpeedControl); err != nil {
+			return err
+		}
+	}
+	if cfg.SpeedMultiplier != 0 {
+		if err := validSpeedMultiplier(cfg.SpeedMultiplier); err != nil {
+			return err
+		}
+	}
+
+	for key, sp := range cfg.Speeds {
+		if !cfg.knownSpeedKey(key) {
+			return fmt.Errorf("speeds has unknown key %q, must be a stage/step in positions or one of %v", key, namedSpeeds)
+		}
+		if err := sp.Arm.validate("speeds." + key + ".arm"); err != nil {
+			return err
+		}
+		if err := sp.BottleArm.validate("speeds." + key + ".bottle_arm"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (cfg *Config) knownSpeedKey(key string) bool {
+	for _, n := range namedSpeeds {
+		if key == n {
+			return true
+		}
+	}
+	for _, pk := range positionKeys {
+		if key == pk.String() {
+			return true
+		}
+	}
+	for stage, steps := range cfg.Positions {
+		for step := range steps {
+			if key == stage+"/"+step {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func validSpeedMultiplier(m float64) error {
+	if m <= 0 || m > 1 {
+		return fmt.Errorf("speed_multiplier must be more than 0 and at most 1, not %v", m)
+	}
+	return nil
+}
+
+func (cfg *Config) speedMultiplier() float64 {
+	if cfg.SpeedMultiplier > 0 {
+		return cfg.SpeedMultiplier
+	}
+	return 1
+}
+
+// speedFor is the configured speed for key, with def for anything not set.
+func (cfg *Config) speedFor(key string, def ArmSpeed) SpeedProfile {
+	sp := cfg.Speeds[key]
+	return SpeedProfile{Arm: sp.Arm.or(def), BottleArm: sp.BottleArm.or(def)}
+}
+
+func (vc *VinoCart) getSpeedMultiplier() float64 {
+	vc.speedLock.Lock()
+	defer vc.speedLock.Unlock()
+	return vc.speedMultiplierNow
+}
+
+// SetSpeedMultiplier scales every speed and acceleration from the next motion on.
+func (vc *VinoCart) SetSpeedMultiplier(m float64) error {
+	if err := validSpeedMultiplier(m); err != nil {
+		return err
+	}
+	vc.speedLock.Lock()
+	defer vc.speedLock.Unlock()
+	vc.logger.Infof("speed multiplier %v -> %v", vc.speedMultiplierNow, m)
+	vc.speedMultiplierNow = m
+	return nil
+}
+
+// setSpeeds sets both arms to the speeds for key, def if it isn't configured.
+func (vc *VinoCart) setSpeeds(ctx context.Context, key string, def ArmSpeed) error {
+	sp := vc.conf.speedFor(key, def)
+	err := vc.applySpeed(ctx, vc.c.Arm, sp.Arm)
+	if err != nil {
+		return err
+	}
+	return vc.applySpeed(ctx, vc.c.BottleArm, sp.BottleArm)
+}
+
+// setArmSpeed sets just the cup arm to its speed for key.
+func (vc *VinoCart) setArmSpeed(ctx context.Context, key string, def ArmSpeed) error {
+	return vc.applySpeed(ctx, vc.c.Arm, vc.conf.speedFor(key, def).Arm)
+}
+
+// setBottleArmSpeed sets just the bottle arm to its speed for key.
+func (vc *VinoCart) setBottleArmSpeed(ctx context.Context, key string, def ArmSpeed) error {
+	return vc.applySpeed(ctx, vc.c.BottleArm, vc.conf.speedFor(key, def).BottleArm)
+}
+
+func (vc *VinoCart) setBottleArmSpeedLog(ctx context.Context, key string, def ArmSpeed) {
+	err := vc.setBottleArmSpeed(ctx, key, def)
+	if err != nil {
+		vc.logger.Errorf("setting bottle arm speed for %s failed: %v", key, err)
+	}
+}
+
+// both is speed and accel the same, like the old hardcoded values.
+func both(x float64) ArmSpeed {
+	return ArmSpeed{Speed: x, Accel: x}
+}
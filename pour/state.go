@@ -0,0 +1,235 @@
+package pour
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"go.viam.com/rdk/logging"
+)
+
+// CycleState is where VinoCart is in the pour cycle.
+type CycleState string
+
+const (
+	StateStarting CycleState = "starting"
+	StateStandby  CycleState = "standby"
+	StateManual   CycleState = "manual mode"
+	StateLooking  CycleState = "looking"
+	StatePicking  CycleState = "picking"
+	StatePrepping CycleState = "prepping"
+	StatePouring  CycleState = "pouring"
+	StatePlacing  CycleState = "placing"
+	StateWaiting  CycleState = "waiting"
+	StateDone     CycleState = "done"
+	StateFailed   CycleState = "failed"
+	StateFaulted  CycleState = "faulted"
+)
+
+// how many transitions we keep around for status
+const maxStateHistory = 50
+
+var idleNextStates = []CycleState{
+	StateLooking, StatePrepping, StatePouring, StatePlacing,
+	StateStandby, StateManual, StateWaiting, StateDone, StateFailed,
+}
+
+// cycleTransitions lists the states each state may move to. Re-entering the
+// current state is always allowed (e.g. looking again when no cup was found).
+var cycleTransitions = map[CycleState][]CycleState{
+	StateStarting: {StateStandby, StateManual, StateFailed},
+	StateStandby:  idleNextStates,
+	StateManual:   idleNextStates,
+	StateDone:     idleNextStates,
+	StateFailed:   idleNextStates,
+	StateLooking:  {StatePicking, StateDone, StateFailed},
+	StatePicking:  {StateLooking, StatePrepping, StateDone, StateFailed},
+	StatePrepping: {StatePouring, StateDone, StateFailed},
+	StatePouring:  {StatePlacing, StateDone, StateFailed},
+	StatePlacing:  {StatePicking, StateWaiting, StateDone, StateFailed}, // picking the next cup in multi-cup mode
+	StateWaiting:  {StateStandby, StateFailed},
+	StateFaulted:  {}, // only clearFault gets out of here
+}
+
+func canTransition(from, to CycleState) bool {
+	if from == to {
+		return true
+	}
+	for _, s := range cycleTransitions[from] {
+		if s == to {
+			return true
+		}
+	}
+	return false
+}
+
+type stateTransition struct {
+	From CycleState
+	To   CycleState
+	At   time.Time
+	Err  string
+}
+
+func (st stateTransition) toMap() map[string]interface{} {
+	m := map[string]interface{}{
+		"from": string(st.From),
+		"to":   string(st.To),
+		"at":   st.At.Format(time.RFC3339Nano),
+	}
+	if st.Err != "" {
+		m["error"] = st.Err
+	}
+	return m
+}
+
+type stateError struct {
+	Err string
+	At  time.Time
+}
+
+// faultInfo is why VinoCart was aborted and what it was doing at the time.
+type faultInfo struct {
+	Reason string
+	State  CycleState
+	Stage  string
+	At     time.Time
+}
+
+type cycleStateMachine struct {
+	logger logging.Logger
+
+	lock       sync.Mutex
+	current    CycleState
+	entered    map[CycleState]time.Time
+	lastErrors map[CycleState]stateError
+	history    []stateTransition
+	fault      *faultInfo
+}
+
+func newCycleStateMachine(initial CycleState, logger logging.Logger) *cycleStateMachine {
+	return &cycleStateMachine{
+		logger:     logger,
+		current:    initial,
+		entered:    map[CycleState]time.Time{initial: time.Now()},
+		lastErrors: map[CycleState]stateError{},
+	}
+}
+
+func (sm *cycleStateMachine) get() CycleState {
+	sm.lock.Lock()
+	defer sm.lock.Unlock()
+	return sm.current
+}
+
+// transition moves to the next state, refusing anything not in cycleTransitions.
+func (sm *cycleStateMachine) transition(to CycleState) error {
+	sm.lock.Lock()
+	defer sm.lock.Unlock()
+	return sm.transitionLocked(to, "")
+}
+
+// fail records err against the current state and moves to StateFailed.
+// A fault stays latched, the error is only recorded.
+func (sm *cycleStateMachine) fail(err error) {
+	sm.lock.Lock()
+	defer sm.lock.Unlock()
+
+	now := time.Now()
+	sm.lastErrors[sm.current] = stateError{Err: err.Error(), At: now}
+	if sm.current == StateFaulted {
+		return
+	}
+	if terr := sm.transitionLocked(StateFailed, err.Error()); terr != nil {
+		sm.logger.Errorf("cannot record failure: %v", terr)
+	}
+}
+
+// latchFault moves to StateFaulted from anywhere, recording why.
+// If already faulted the first fault is kept.
+func (sm *cycleStateMachine) latchFault(reason, stage string) faultInfo {
+	sm.lock.Lock()
+	defer sm.lock.Unlock()
+
+	if sm.current != StateFaulted {
+		sm.fault = &faultInfo{Reason: reason, State: sm.current, Stage: stage, At: time.Now()}
+		sm.forceLocked(StateFaulted, reason)
+	}
+	return *sm.fault
+}
+
+// clearFault leaves StateFaulted for to.
+func (sm *cycleStateMachine) clearFault(to CycleState) error {
+	sm.lock.Lock()
+	defer sm.lock.Unlock()
+
+	if sm.current != StateFaulted {
+		return fmt.Errorf("not faulted, state is %q", sm.current)
+	}
+
+	sm.fault = nil
+	sm.forceLocked(to, "")
+	return nil
+}
+
+func (sm *cycleStateMachine) transitionLocked(to CycleState, errString string) error {
+	from := sm.current
+	if !canTransition(from, to) {
+		return fmt.Errorf("invalid state transition %q -> %q", from, to)
+	}
+
+	sm.forceLocked(to, errString)
+	return nil
+}
+
+func (sm *cycleStateMachine) forceLocked(to CycleState, errString string) {
+	now := time.Now()
+	sm.history = append(sm.history, stateTransition{From: sm.current, To: to, At: now, Err: errString})
+	if len(sm.history) > maxStateHistory {
+		sm.history = sm.history[len(sm.history)-maxStateHistory:]
+	}
+	sm.current = to
+	sm.entered[to] = now
+}
+
+// status is the structured form returned by the status DoCommand.
+func (sm *cycleStateMachine) status() map[string]interface{} {
+	sm.lock.Lock()
+	defer sm.lock.Unlock()
+
+	entered := map[string]interface{}{}
+	for s, t := range sm.entered {
+		entered[string(s)] = t.Format(time.RFC3339Nano)
+	}
+
+	lastErrors := map[string]interface{}{}
+	for s, e := range sm.lastErrors {
+		lastErrors[string(s)] = map[string]interface{}{
+			"error": e.Err,
+			"at":    e.At.Format(time.RFC3339Nano),
+		}
+	}
+
+	history := []interface{}{}
+	for _, h := range sm.history {
+		history = append(history, h.toMap())
+	}
+
+	since := sm.entered[sm.current]
+	m := map[string]interface{}{
+		"status":      string(sm.current),
+		"since":       since.Format(time.RFC3339Nano),
+		"in_state_ms": float64(time.Since(since).Milliseconds()),
+		"entered":     entered,
+		"last_errors": lastErrors,
+		"history":     history,
+	}
+	if sm.fault != nil {
+		m["fault"] = map[string]interface{}{
+			"reason": sm.fault.Reason,
+			"state":  string(sm.fault.State),
+			"stage":  sm.fault.Stage,
+			"at":     sm.fault.At.Format(time.RFC3339Nano),
+		}
+	}
+	return m
+}
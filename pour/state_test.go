@@ -0,0 +1,84 @@
+package pour
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"go.viam.com/rdk/logging"
+	"go.viam.com/test"
+)
+
+func TestCycleStateMachine(t *testing.T) {
+	sm := newCycleStateMachine(StateManual, logging.NewTestLogger(t))
+
+	test.That(t, sm.transition(StateLooking), test.ShouldBeNil)
+	test.That(t, sm.transition(StateLooking), test.ShouldBeNil)
+	test.That(t, sm.transition(StatePicking), test.ShouldBeNil)
+
+	// can't skip from picking to placing
+	test.That(t, sm.transition(StatePlacing), test.ShouldNotBeNil)
+	test.That(t, sm.get(), test.ShouldEqual, StatePicking)
+
+	sm.fail(fmt.Errorf("bad pick"))
+	test.That(t, sm.get(), test.ShouldEqual, StateFailed)
+
+	s := sm.status()
+	test.That(t, s["status"], test.ShouldEqual, "failed")
+	lastErrors := s["last_errors"].(map[string]interface{})
+	test.That(t, lastErrors["picking"].(map[string]interface{})["error"], test.ShouldEqual, "bad pick")
+	test.That(t, len(s["history"].([]interface{})), test.ShouldEqual, 4)
+
+	test.That(t, sm.transition(StateDone), test.ShouldBeNil)
+}
+
+func TestCycleStateMachineHistoryBounded(t *testing.T) {
+	sm := newCycleStateMachine(StateManual, logging.NewTestLogger(t))
+	for i := 0; i < maxStateHistory*2; i++ {
+		test.That(t, sm.transition(StateDone), test.ShouldBeNil)
+	}
+	test.That(t, len(sm.history), test.ShouldEqual, maxStateHistory)
+}
+
+func TestCycleStateMachineFault(t *testing.T) {
+	sm := newCycleStateMachine(StateManual, logging.NewTestLogger(t))
+	test.That(t, sm.transition(StatePouring), test.ShouldBeNil)
+
+	f := sm.latchFault("operator hit stop", "pour_loop")
+	test.That(t, f.State, test.ShouldEqual, StatePouring)
+	test.That(t, sm.get(), test.ShouldEqual, StateFaulted)
+
+	// nothing gets out of a fault but clearFault, and failures don't unlatch it
+	test.That(t, sm.transition(StatePlacing), test.ShouldNotBeNil)
+	sm.fail(fmt.Errorf("context canceled"))
+	test.That(t, sm.get(), test.ShouldEqual, StateFaulted)
+
+	fault := sm.status()["fault"].(map[string]interface{})
+	test.That(t, fault["reason"], test.ShouldEqual, "operator hit stop")
+	test.That(t, fault["state"], test.ShouldEqual, "pouring")
+
+	test.That(t, sm.clearFault(StateManual), test.ShouldBeNil)
+	test.That(t, sm.get(), test.ShouldEqual, StateManual)
+	test.That(t, sm.clearFault(StateManual), test.ShouldNotBeNil)
+}
+
+func TestSimClearAreaState(t *testing.T) {
+	ctx := context.Background()
+	vc, cart := newSimVinoCart(t)
+	putCup(t, cart, 450, 0)
+
+	// what the loop does when it has to wait for the last cup to go first
+	vc.setStatus(StateStandby)
+	var waiting CycleState
+	err := vc.waitForClearArea(ctx, func() bool {
+		waiting = vc.getStatus()
+		return false
+	})
+	test.That(t, err, test.ShouldEqual, errLoopNotRunning)
+	test.That(t, waiting, test.ShouldEqual, StateWaiting)
+	test.That(t, vc.state.status()["status"], test.ShouldEqual, "waiting")
+
+	for _, s := range []CycleState{StateStandby, StateManual, StateDone, StateFailed} {
+		test.That(t, canTransition(s, StateWaiting), test.ShouldBeTrue)
+	}
+}
@@ -0,0 +1,276 @@
+package pour
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"go.viam.com/rdk/referenceframe"
+	"go.viam.com/rdk/spatialmath"
+)
+
+// defaultTiltCachePath is where planned pour tilts are kept between restarts.
+var defaultTiltCachePath = func() string {
+	cacheDir, err := os.UserCacheDir()
+	if err != nil {
+		return "/tmp/viam-pour-tilt-cache.json"
+	}
+
+	return filepath.Join(cacheDir, "viam-pour-tilt-cache.json")
+}()
+
+// tilt cache keys round start joints to tiltCacheJointStep radians, the bottle top
+// to tiltCachePointStep mm and its orientation vector to tiltCacheOVStep
+const (
+	tiltCacheJointStep = .01
+	tiltCachePointStep = 1
+	tiltCacheOVStep    = .01
+	tiltCacheThetaStep = 1 // degrees
+
+	tiltCacheMaxEntries = 50
+)
+
+// tilt cache lookup results, also the metric label
+const (
+	tiltCacheHit         = "hit"
+	tiltCacheMiss        = "miss"
+	tiltCacheInvalidated = "invalidated" // frame system or obstacles changed
+	tiltCacheRejected    = "rejected"    // found, but too far from where we are
+)
+
+// tiltCacheEntry is one planned tilt.
+type tiltCacheEntry struct {
+	// Fingerprint is of the frame system and obstacles it was planned with
+	Fingerprint string                   `json:"fingerprint"`
+	StartJoints []referenceframe.Input   `json:"start_joints"`
+	Joints      [][]referenceframe.Input `json:"joints"`
+	Created     time.Time                `json:"created"`
+	Used        time.Time                `json:"used"`
+}
+
+// check is nil if the entry can be used from start for poses, with no step
+// further than maxStep.
+func (e *tiltCacheEntry) check(start []referenceframe.Input, poses []*referenceframe.PoseInFrame, maxStep float64) error {
+	if len(e.Joints) != len(poses) {
+		return fmt.Errorf("has %d steps, want %d", len(e.Joints), len(poses))
+	}
+	if len(e.StartJoints) != len(start) {
+		return fmt.Errorf("has %d joints, arm has %d", len(e.StartJoints), len(start))
+	}
+	if d := referenceframe.InputsL2Distance(start, e.StartJoints); d > maxStep {
+		return fmt.Errorf("start is %.3f from the cached start", d)
+	}
+	for i := 1; i < len(e.Joints); i++ {
+		if d := referenceframe.InputsL2Distance(e.Joints[i-1], e.Joints[i]); d > maxStep {
+			return fmt.Errorf("step %d is %.3f", i, d)
+		}
+	}
+	return nil
+}
+
+type tiltCacheFile struct {
+	Entries map[string]*tiltCacheEntry `json:"entries"`
+}
+
+// tiltCache is a JSON file of planned pour tilts, keyed by where the tilt starts.
+type tiltCache struct {
+	lock   sync.Mutex
+	path   string
+	loaded bool
+	file   tiltCacheFile
+}
+
+func newTiltCache(path string) *tiltCache {
+	if path == "" {
+		path = defaultTiltCachePath
+	}
+	return &tiltCache{path: path}
+}
+
+func (tc *tiltCache) setPath(path string) {
+	if path == "" {
+		path = defaultTiltCachePath
+	}
+	tc.lock.Lock()
+	defer tc.lock.Unlock()
+	tc.path = path
+	tc.loaded = false
+}
+
+// loadLocked reads the file the first time it's needed, a missing or bad file is
+// an empty cache.
+func (tc *tiltCache) loadLocked() error {
+	if tc.loaded {
+		return nil
+	}
+	tc.loaded = true
+	tc.file = tiltCacheFile{Entries: map[string]*tiltCacheEntry{}}
+
+	data, err := os.ReadFile(tc.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	f := tiltCacheFile{}
+	err = json.Unmarshal(data, &f)
+	if err != nil {
+		return fmt.Errorf("bad tilt cache %s: %w", tc.path, err)
+	}
+	if f.Entries != nil {
+		tc.file = f
+	}
+	return nil
+}
+
+func (tc *tiltCache) saveLocked() error {
+	data, err := json.Marshal(tc.file)
+	if err != nil {
+		return err
+	}
+	err = os.MkdirAll(filepath.Dir(tc.path), 0o755)
+	if err != nil {
+		return err
+	}
+	tmp := tc.path + ".tmp"
+	err = os.WriteFile(tmp, data, 0o644)
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp, tc.path)
+}
+
+// get is the entry for key planned with fingerprint, and one of the tilt cache
+// results. An entry for key from another fingerprint is dropped, the others are
+// kept since a recipe can change the frames and change them back.
+func (tc *tiltCache) get(fingerprint, key string) (*tiltCacheEntry, string, error) {
+	tc.lock.Lock()
+	defer tc.lock.Unlock()
+
+	err := tc.loadLocked()
+	if err != nil {
+		return nil, tiltCacheMiss, err
+	}
+
+	e, ok := tc.file.Entries[key]
+	if !ok {
+		return nil, tiltCacheMiss, nil
+	}
+	if e.Fingerprint != fingerprint {
+		delete(tc.file.Entries, key)
+		return nil, tiltCacheInvalidated, tc.saveLocked()
+	}
+	e.Used = time.Now()
+	return e, tiltCacheHit, nil
+}
+
+// put saves e under key, dropping the least recently used entries past tiltCacheMaxEntries.
+func (tc *tiltCache) put(fingerprint, key string, e *tiltCacheEntry) error {
+	tc.lock.Lock()
+	defer tc.lock.Unlock()
+
+	err := tc.loadLocked()
+	if err != nil {
+		return err
+	}
+
+	e.Fingerprint = fingerprint
+	e.Created = time.Now()
+	e.Used = e.Created
+	tc.file.Entries[key] = e
+
+	for len(tc.file.Entries) > tiltCacheMaxEntries {
+		oldest := ""
+		for k, x := range tc.file.Entries {
+			if oldest == "" || x.Used.Before(tc.file.Entries[oldest].Used) {
+				oldest = k
+			}
+		}
+		delete(tc.file.Entries, oldest)
+	}
+
+	return tc.saveLocked()
+}
+
+// clear empties the cache, returning how many entries it had.
+func (tc *tiltCache) clear() (int, error) {
+	tc.lock.Lock()
+	defer tc.lock.Unlock()
+
+	err := tc.loadLocked()
+	if err != nil {
+		return 0, err
+	}
+	n := len(tc.file.Entries)
+	tc.file = tiltCacheFile{Entries: map[string]*tiltCacheEntry{}}
+	return n, tc.saveLocked()
+}
+
+// tiltCacheKey is where a tilt starts, rounded, and how it tilts.
+func tiltCacheKey(profile PourProfile, startJoints []referenceframe.Input, bottleStart spatialmath.Pose) string {
+	round := func(v, step float64) int64 {
+		return int64(math.Round(v / step))
+	}
+
+	joints := []int64{}
+	for _, j := range startJoints {
+		joints = append(joints, round(j, tiltCacheJointStep))
+	}
+	p := bottleStart.Point()
+	o := bottleStart.Orientation().OrientationVectorDegrees()
+	drift := profile.drift()
+
+	return fmt.Sprintf("joints:%v top:%d,%d,%d ov:%d,%d,%d,%d tilt:%g/%g drift:%g,%g,%g",
+		joints,
+		round(p.X, tiltCachePointStep), round(p.Y, tiltCachePointStep), round(p.Z, tiltCachePointStep),
+		round(o.OX, tiltCacheOVStep), round(o.OY, tiltCacheOVStep), round(o.OZ, tiltCacheOVStep), round(o.Theta, tiltCacheThetaStep),
+		profile.tiltStep(), profile.tiltStop(),
+		drift.X, drift.Y, drift.Z,
+	)
+}
+
+// tiltFingerprint changes when any frame in fs, including bottle-top, or any
+// obstacle changes.
+func tiltFingerprint(fs *referenceframe.FrameSystem, obstacles []*referenceframe.GeometriesInFrame) (string, error) {
+	h := sha256.New()
+
+	names := fs.FrameNames()
+	sort.Strings(names)
+	for _, name := range names {
+		f := fs.Frame(name)
+		parent, err := fs.Parent(f)
+		if err != nil {
+			return "", err
+		}
+		data, err := json.Marshal(f)
+		if err != nil {
+			return "", fmt.Errorf("can't fingerprint frame %s: %w", name, err)
+		}
+		fmt.Fprintf(h, "%s<-%s:%s\n", name, parent.Name(), data)
+	}
+
+	for _, gif := range obstacles {
+		for _, g := range gif.Geometries() {
+			data, err := json.Marshal(describeGeometry(gif.Parent(), g))
+			if err != nil {
+				return "", err
+			}
+			fmt.Fprintf(h, "obstacle:%s\n", data)
+			// describeGeometry only has a mesh's size
+			if mesh := g.ToProtobuf().GetMesh(); mesh != nil {
+				fmt.Fprintf(h, "mesh:%x\n", sha256.Sum256(mesh.GetMesh()))
+			}
+		}
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
@@ -0,0 +1,121 @@
+package pour
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/golang/geo/r3"
+	"go.viam.com/test"
+
+	"go.viam.com/rdk/referenceframe"
+	"go.viam.com/rdk/spatialmath"
+)
+
+func TestTiltCache(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tilt-cache.json")
+	start := []referenceframe.Input{0, 1, 0, 1, 0, 1}
+	joints := [][]referenceframe.Input{{0, 1, 0, 1, 0, 1.1}, {0, 1, 0, 1, 0, 1.2}}
+
+	tc := newTiltCache(path)
+	e, result, err := tc.get("a", "k")
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, e, test.ShouldBeNil)
+	test.That(t, result, test.ShouldEqual, tiltCacheMiss)
+	test.That(t, tc.put("a", "k", &tiltCacheEntry{StartJoints: start, Joints: joints}), test.ShouldBeNil)
+
+	// survives a restart
+	tc = newTiltCache(path)
+	e, result, err = tc.get("a", "k")
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, result, test.ShouldEqual, tiltCacheHit)
+	test.That(t, e.Joints, test.ShouldResemble, joints)
+
+	// another frame system doesn't touch other keys
+	test.That(t, tc.put("b", "k2", &tiltCacheEntry{StartJoints: start, Joints: joints}), test.ShouldBeNil)
+	_, result, err = tc.get("a", "k")
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, result, test.ShouldEqual, tiltCacheHit)
+	_, result, err = tc.get("b", "k2")
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, result, test.ShouldEqual, tiltCacheHit)
+
+	// but drops the entry for the same key
+	e, result, err = tc.get("b", "k")
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, e, test.ShouldBeNil)
+	test.That(t, result, test.ShouldEqual, tiltCacheInvalidated)
+	_, result, err = newTiltCache(path).get("a", "k")
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, result, test.ShouldEqual, tiltCacheMiss)
+
+	test.That(t, tc.put("a", "k", &tiltCacheEntry{StartJoints: start, Joints: joints}), test.ShouldBeNil)
+	n, err := tc.clear()
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, n, test.ShouldEqual, 2)
+}
+
+func TestTiltFingerprintMesh(t *testing.T) {
+	fs := referenceframe.NewEmptyFrameSystem("test")
+	dir := t.TempDir()
+	fingerprint := func(y string) string {
+		fn := filepath.Join(dir, "screen.ply")
+		ply := "ply\nformat ascii 1.0\nelement vertex 3\nproperty float x\nproperty float y\nproperty float z\n" +
+			"element face 1\nproperty list uchar int vertex_indices\nend_header\n" +
+			"0 0 0\n100 0 0\n0 " + y + " 0\n3 0 1 2\n"
+		test.That(t, os.WriteFile(fn, []byte(ply), 0o644), test.ShouldBeNil)
+		g, err := (&Obstacle{Name: "screen", Type: obstacleMesh, MeshFile: fn}).geometry()
+		test.That(t, err, test.ShouldBeNil)
+		fp, err := tiltFingerprint(fs, []*referenceframe.GeometriesInFrame{
+			referenceframe.NewGeometriesInFrame(referenceframe.World, []spatialmath.Geometry{g}),
+		})
+		test.That(t, err, test.ShouldBeNil)
+		return fp
+	}
+	// same size, different shape
+	test.That(t, fingerprint("100"), test.ShouldNotEqual, fingerprint("200"))
+}
+
+func TestTiltCacheEntryCheck(t *testing.T) {
+	pp := PourProfile{}
+	poses := pourTiltPoses(pp, spatialmath.NewPose(r3.Vector{X: 400}, &spatialmath.OrientationVectorDegrees{OX: 1}))
+	test.That(t, len(poses), test.ShouldBeGreaterThan, 1)
+
+	start := []referenceframe.Input{0, 0}
+	e := &tiltCacheEntry{StartJoints: start}
+	for i := range poses {
+		e.Joints = append(e.Joints, []referenceframe.Input{0, float64(i) * .01})
+	}
+	test.That(t, e.check(start, poses, pp.maxStepL2()), test.ShouldBeNil)
+	test.That(t, e.check([]referenceframe.Input{0, 0.001}, poses, pp.maxStepL2()), test.ShouldBeNil)
+
+	err := e.check([]referenceframe.Input{1, 0}, poses, pp.maxStepL2())
+	test.That(t, err, test.ShouldNotBeNil)
+	test.That(t, err.Error(), test.ShouldContainSubstring, "from the cached start")
+
+	err = e.check(start, poses[1:], pp.maxStepL2())
+	test.That(t, err, test.ShouldNotBeNil)
+	test.That(t, err.Error(), test.ShouldContainSubstring, "steps")
+
+	e.Joints[1] = []referenceframe.Input{5, 5}
+	test.That(t, e.check(start, poses, pp.maxStepL2()), test.ShouldNotBeNil)
+}
+
+func TestSimTiltCache(t *testing.T) {
+	ctx := context.Background()
+	vc, cart := newSimVinoCart(t)
+	putCup(t, cart, 450, 0)
+
+	test.That(t, vc.FullDemo(ctx), test.ShouldBeNil)
+	test.That(t, vc.metrics.tiltCache.get([]string{tiltCacheMiss}).value, test.ShouldEqual, 1)
+	test.That(t, len(vc.tiltCache.file.Entries), test.ShouldEqual, 1)
+
+	// back where the pour started, the tilt comes from the cache
+	for _, e := range vc.tiltCache.file.Entries {
+		test.That(t, cart.BottleArm.MoveToJointPositions(ctx, e.StartJoints, nil), test.ShouldBeNil)
+	}
+	_, err := vc.SetupPourPositions(ctx)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, vc.metrics.tiltCache.get([]string{tiltCacheHit}).value, test.ShouldEqual, 1)
+}
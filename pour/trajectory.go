@@ -0,0 +1,343 @@
+package pour
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/golang/geo/r3"
+
+	"go.viam.com/rdk/components/arm"
+	"go.viam.com/rdk/referenceframe"
+	"go.viam.com/rdk/spatialmath"
+
+	"github.com/erh/vmodutils/touch"
+)
+
+// each segment of a trajectory is checked at this many points, the arm moves
+// roughly linearly in joint space between them
+const trajectorySamples = 10
+
+// below this a wrist joint is at its singularity, so it has no side to flip from
+const wristFlipDeadband = .05
+
+// errBadTrajectory is wrapped by every limit a trajectory breaks, so callers can
+// tell a plan that needs replanning from a move that failed.
+var errBadTrajectory = fmt.Errorf("bad trajectory")
+
+// TrajectoryLimits are sanity checks on a trajectory before an arm runs it, zero
+// is no limit.
+type TrajectoryLimits struct {
+	// radians any one joint can turn between two steps
+	MaxJointDelta float64 `json:"max_joint_delta,omitempty"`
+	// joint distance between two steps
+	MaxStepL2 float64 `json:"max_step_l2,omitempty"`
+	// joint distance over the whole trajectory
+	MaxTravel float64 `json:"max_travel,omitempty"`
+	// fail if the wrist goes through its singularity to the other side
+	NoWristFlip bool `json:"no_wrist_flip,omitempty"`
+	// mm the gripper can stray from a straight line between the start and end
+	MaxPathDeviationMM float64 `json:"max_path_deviation_mm,omitempty"`
+	// the gripper has to stay in one of these
+	Workspace []WorkspaceBox `json:"workspace,omitempty"`
+}
+
+// WorkspaceBox is an axis aligned box in the world frame.
+type WorkspaceBox struct {
+	Name string    `json:"name,omitempty"`
+	Min  r3.Vector `json:"min"`
+	Max  r3.Vector `json:"max"`
+}
+
+func (wb *WorkspaceBox) contains(p r3.Vector) bool {
+	return p.X >= wb.Min.X && p.X <= wb.Max.X &&
+		p.Y >= wb.Min.Y && p.Y <= wb.Max.Y &&
+		p.Z >= wb.Min.Z && p.Z <= wb.Max.Z
+}
+
+func (tl *TrajectoryLimits) Validate(path string) error {
+	if tl.MaxJointDelta < 0 {
+		return fmt.Errorf("%s.max_joint_delta can't be negative", path)
+	}
+	if tl.MaxStepL2 < 0 {
+		return fmt.Errorf("%s.max_step_l2 can't be negative", path)
+	}
+	if tl.MaxTravel < 0 {
+		return fmt.Errorf("%s.max_travel can't be negative", path)
+	}
+	if tl.MaxPathDeviationMM < 0 {
+		return fmt.Errorf("%s.max_path_deviation_mm can't be negative", path)
+	}
+	for idx, wb := range tl.Workspace {
+		if wb.Min.X > wb.Max.X || wb.Min.Y > wb.Max.Y || wb.Min.Z > wb.Max.Z {
+			return fmt.Errorf("%s.workspace.%d min has to be below max", path, idx)
+		}
+	}
+	return nil
+}
+
+// needsPoses is if checking needs where the gripper is, not just the joints.
+func (tl *TrajectoryLimits) needsPoses() bool {
+	return tl.MaxPathDeviationMM > 0 || len(tl.Workspace) > 0
+}
+
+// with is tl with everything set in o replacing it.
+func (tl TrajectoryLimits) with(o TrajectoryLimits) TrajectoryLimits {
+	if o.MaxJointDelta != 0 {
+		tl.MaxJointDelta = o.MaxJointDelta
+	}
+	if o.MaxStepL2 != 0 {
+		tl.MaxStepL2 = o.MaxStepL2
+	}
+	if o.MaxTravel != 0 {
+		tl.MaxTravel = o.MaxTravel
+	}
+	if o.NoWristFlip {
+		tl.NoWristFlip = true
+	}
+	if o.MaxPathDeviationMM != 0 {
+		tl.MaxPathDeviationMM = o.MaxPathDeviationMM
+	}
+	if len(o.Workspace) > 0 {
+		tl.Workspace = o.Workspace
+	}
+	return tl
+}
+
+// trajectoryLimits are the limits for moves tagged tag: trajectory_limits for the
+// tag, else our own for the tag with anything set in trajectory_limits default
+// on top.
+func (cfg *Config) trajectoryLimits(tag string) TrajectoryLimits {
+	if tl, ok := cfg.TrajectoryLimits[tag]; ok {
+		return tl
+	}
+
+	pp := cfg.pourProfile()
+	var tl TrajectoryLimits
+	switch tag {
+	case "bottle-to-cup-align":
+		tl = TrajectoryLimits{MaxStepL2: pp.maxAlignL2()}
+	case "pour-tilt", "pour-return":
+		tl = TrajectoryLimits{MaxJointDelta: math.Pi, MaxStepL2: pp.maxStepL2(), NoWristFlip: true}
+	default:
+		// a joint turning half way around in one step is an IK flip
+		tl = TrajectoryLimits{MaxJointDelta: math.Pi}
+	}
+	return tl.with(cfg.TrajectoryLimits["default"])
+}
+
+// checkTrajectory is every way traj breaks limits, nil if it doesn't. poses is
+// where the gripper is at each sample, and only needed for the limits that say so.
+func checkTrajectory(traj [][]referenceframe.Input, poses []spatialmath.Pose, limits TrajectoryLimits) error {
+	problems := []string{}
+
+	travel := 0.
+	for i := 1; i < len(traj); i++ {
+		if len(traj[i]) != len(traj[i-1]) {
+			return fmt.Errorf("step %d has %d joints, step %d has %d", i, len(traj[i]), i-1, len(traj[i-1]))
+		}
+		d := referenceframe.InputsL2Distance(traj[i-1], traj[i])
+		travel += d
+		if limits.MaxStepL2 > 0 && d > limits.MaxStepL2 {
+			problems = append(problems, fmt.Sprintf("step %d moves %.3f, max %.3f", i, d, limits.MaxStepL2))
+		}
+		for j := range traj[i] {
+			delta := math.Abs(traj[i][j] - traj[i-1][j])
+			if limits.MaxJointDelta > 0 && delta > limits.MaxJointDelta {
+				problems = append(problems, fmt.Sprintf("step %d turns joint %d %.3f, max %.3f", i, j, delta, limits.MaxJointDelta))
+			}
+		}
+	}
+	if limits.MaxTravel > 0 && travel > limits.MaxTravel {
+		problems = append(problems, fmt.Sprintf("travels %.3f, max %.3f", travel, limits.MaxTravel))
+	}
+
+	if limits.NoWristFlip {
+		if step, ok := wristFlip(traj); ok {
+			problems = append(problems, fmt.Sprintf("wrist flips at step %d", step))
+		}
+	}
+
+	if limits.needsPoses() && len(poses) > 0 {
+		start, end := poses[0].Point(), poses[len(poses)-1].Point()
+		worst := 0.
+		for _, p := range poses {
+			worst = math.Max(worst, distanceToSegment(p.Point(), start, end))
+		}
+		if limits.MaxPathDeviationMM > 0 && worst > limits.MaxPathDeviationMM {
+			problems = append(problems, fmt.Sprintf("gripper strays %.0fmm from a straight line, max %.0fmm", worst, limits.MaxPathDeviationMM))
+		}
+
+		if len(limits.Workspace) > 0 {
+			for i, p := range poses {
+				if !inWorkspace(limits.Workspace, p.Point()) {
+					problems = append(problems, fmt.Sprintf("gripper leaves the workspace at %v (sample %d)", p.Point(), i))
+					break
+				}
+			}
+		}
+	}
+
+	if len(problems) == 0 {
+		return nil
+	}
+	return fmt.Errorf("%w: %s", errBadTrajectory, strings.Join(problems, "; "))
+}
+
+// wristFlip is the first step where the wrist's middle joint, second from the
+// end, is on the other side of zero from where it started.
+func wristFlip(traj [][]referenceframe.Input) (int, bool) {
+	if len(traj) == 0 || len(traj[0]) < 5 {
+		return 0, false
+	}
+	w := len(traj[0]) - 2
+	side := 0.
+	for i, step := range traj {
+		v := step[w]
+		if math.Abs(v) < wristFlipDeadband {
+			continue
+		}
+		if side == 0 {
+			side = math.Copysign(1, v)
+			continue
+		}
+		if math.Copysign(1, v) != side {
+			return i, true
+		}
+	}
+	return 0, false
+}
+
+func inWorkspace(boxes []WorkspaceBox, p r3.Vector) bool {
+	for _, wb := range boxes {
+		if wb.contains(p) {
+			return true
+		}
+	}
+	return false
+}
+
+// distanceToSegment is how far p is from the segment a to b.
+func distanceToSegment(p, a, b r3.Vector) float64 {
+	ab := b.Sub(a)
+	if ab.Norm2() == 0 {
+		return p.Sub(a).Norm()
+	}
+	t := math.Max(0, math.Min(1, p.Sub(a).Dot(ab)/ab.Norm2()))
+	return p.Sub(a.Add(ab.Mul(t))).Norm()
+}
+
+// sampleTrajectory is traj with trajectorySamples points on each segment, joint
+// space linear like the arm moves.
+func sampleTrajectory(traj [][]referenceframe.Input) [][]referenceframe.Input {
+	if len(traj) < 2 {
+		return traj
+	}
+	samples := [][]referenceframe.Input{traj[0]}
+	for i := 1; i < len(traj); i++ {
+		for s := 1; s <= trajectorySamples; s++ {
+			f := float64(s) / trajectorySamples
+			step := make([]referenceframe.Input, len(traj[i]))
+			for j := range step {
+				step[j] = traj[i-1][j] + (traj[i][j]-traj[i-1][j])*f
+			}
+			samples = append(samples, step)
+		}
+	}
+	return samples
+}
+
+// armGripper is the gripper on a.
+func (vc *VinoCart) armGripper(a arm.Arm) string {
+	if vc.c.BottleArm != nil && a.Name() == vc.c.BottleArm.Name() {
+		return vc.conf.BottleGripper
+	}
+	return vc.conf.GripperName
+}
+
+// gripperPoses is where a's gripper is, in world, at each step of traj.
+func (vc *VinoCart) gripperPoses(ctx context.Context, a arm.Arm, traj [][]referenceframe.Input) ([]spatialmath.Pose, error) {
+	armName, gripper := a.Name().ShortName(), vc.armGripper(a)
+	fs, err := touch.FrameSystemWithSomeParts(ctx, vc.c.Rfs, []string{armName, gripper}, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	poses := []spatialmath.Pose{}
+	for _, joints := range sampleTrajectory(traj) {
+		inputs := referenceframe.FrameSystemInputs{armName: joints}
+		for _, name := range fs.FrameNames() {
+			if _, ok := inputs[name]; !ok && len(fs.Frame(name).DoF()) == 0 {
+				inputs[name] = []referenceframe.Input{}
+			}
+		}
+		tf, err := fs.Transform(inputs, referenceframe.NewPoseInFrame(gripper, spatialmath.NewZeroPose()), referenceframe.World)
+		if err != nil {
+			return nil, err
+		}
+		pif, ok := tf.(*referenceframe.PoseInFrame)
+		if !ok {
+			return nil, fmt.Errorf("transform of %s gave a %T", gripper, tf)
+		}
+		poses = append(poses, pif.Pose())
+	}
+	return poses, nil
+}
+
+// validateMove checks a moving from where it is through positions against the
+// limits for tag, writing the trajectory to planDir if it fails.
+func (vc *VinoCart) validateMove(ctx context.Context, a arm.Arm, tag string, positions [][]referenceframe.Input) error {
+	limits := vc.conf.trajectoryLimits(tag)
+
+	start, err := a.JointPositions(ctx, nil)
+	if err != nil {
+		return err
+	}
+	traj := append([][]referenceframe.Input{start}, positions...)
+
+	var poses []spatialmath.Pose
+	if limits.needsPoses() {
+		poses, err = vc.gripperPoses(ctx, a, traj)
+		if err != nil {
+			return fmt.Errorf("can't check %s trajectory: %w", tag, err)
+		}
+	}
+
+	err = checkTrajectory(traj, poses, limits)
+	if err == nil {
+		return nil
+	}
+	err = fmt.Errorf("%s: %w", tag, err)
+
+	fn, writeErr := writeBadTrajectory(tag, a.Name().ShortName(), traj, limits, err)
+	if writeErr != nil {
+		vc.logger.Errorf("failed to write bad %s trajectory: %v", tag, writeErr)
+	} else {
+		vc.logger.Warnf("bad %s trajectory written to %s", tag, fn)
+	}
+	return err
+}
+
+func writeBadTrajectory(tag, armName string, traj [][]referenceframe.Input, limits TrajectoryLimits, problem error) (string, error) {
+	data, err := json.MarshalIndent(map[string]interface{}{
+		"tag":        tag,
+		"arm":        armName,
+		"error":      problem.Error(),
+		"limits":     limits,
+		"trajectory": traj,
+	}, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	err = os.MkdirAll(planDir, 0o755)
+	if err != nil {
+		return "", err
+	}
+	fn := filepath.Join(planDir, fmt.Sprintf("trajectory-bad-%s-%d.json", planRecordUnsafe.ReplaceAllString(tag, "_"), time.Now().UnixNano()))
+	return fn, os.WriteFile(fn, data, 0o644)
+}
@@ -0,0 +1,213 @@
+package pour
+
+import (
+	"context"
+	"math"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/golang/geo/r3"
+	"go.viam.com/test"
+
+	"go.viam.com/rdk/components/arm"
+	"go.viam.com/rdk/referenceframe"
+	"go.viam.com/rdk/spatialmath"
+
+	"github.com/viam-modules/viam-pouring-demo/pour/sim"
+)
+
+func TestCheckTrajectory(t *testing.T) {
+	traj := [][]referenceframe.Input{
+		{0, 0, 0, 0, .5, 0},
+		{.1, 0, 0, 0, .4, 0},
+		{.2, 0, 0, 0, .3, 0},
+	}
+	test.That(t, checkTrajectory(traj, nil, TrajectoryLimits{MaxJointDelta: .2, MaxStepL2: .2, MaxTravel: .5, NoWristFlip: true}), test.ShouldBeNil)
+
+	err := checkTrajectory(traj, nil, TrajectoryLimits{MaxJointDelta: .05})
+	test.That(t, err, test.ShouldNotBeNil)
+	test.That(t, err.Error(), test.ShouldContainSubstring, "step 1 turns joint 0")
+
+	err = checkTrajectory(traj, nil, TrajectoryLimits{MaxTravel: .2})
+	test.That(t, err, test.ShouldNotBeNil)
+	test.That(t, err.Error(), test.ShouldContainSubstring, "travels")
+
+	flip := append(traj, []referenceframe.Input{.3, 0, 0, 0, -.3, 0})
+	test.That(t, checkTrajectory(flip, nil, TrajectoryLimits{}), test.ShouldBeNil)
+	err = checkTrajectory(flip, nil, TrajectoryLimits{NoWristFlip: true})
+	test.That(t, err, test.ShouldNotBeNil)
+	test.That(t, err.Error(), test.ShouldContainSubstring, "wrist flips at step 3")
+
+	// passing through the singularity isn't a flip on its own
+	_, ok := wristFlip([][]referenceframe.Input{{0, 0, 0, 0, .5, 0}, {0, 0, 0, 0, 0, 0}, {0, 0, 0, 0, .5, 0}})
+	test.That(t, ok, test.ShouldBeFalse)
+
+	poses := []spatialmath.Pose{
+		spatialmath.NewPoseFromPoint(r3.Vector{X: 0}),
+		spatialmath.NewPoseFromPoint(r3.Vector{X: 50, Z: 30}),
+		spatialmath.NewPoseFromPoint(r3.Vector{X: 100}),
+	}
+	test.That(t, checkTrajectory(traj, poses, TrajectoryLimits{MaxPathDeviationMM: 40}), test.ShouldBeNil)
+	err = checkTrajectory(traj, poses, TrajectoryLimits{MaxPathDeviationMM: 20})
+	test.That(t, err, test.ShouldNotBeNil)
+	test.That(t, err.Error(), test.ShouldContainSubstring, "strays 30mm")
+
+	box := WorkspaceBox{Min: r3.Vector{X: -10, Y: -10, Z: -10}, Max: r3.Vector{X: 110, Y: 10, Z: 10}}
+	err = checkTrajectory(traj, poses, TrajectoryLimits{Workspace: []WorkspaceBox{box}})
+	test.That(t, err, test.ShouldNotBeNil)
+	test.That(t, err.Error(), test.ShouldContainSubstring, "sample 1")
+	box.Max.Z = 50
+	test.That(t, checkTrajectory(traj, poses, TrajectoryLimits{Workspace: []WorkspaceBox{box}}), test.ShouldBeNil)
+
+	test.That(t, len(sampleTrajectory(traj)), test.ShouldEqual, 1+2*trajectorySamples)
+}
+
+func TestTrajectoryLimits(t *testing.T) {
+	cfg := &Config{}
+	test.That(t, cfg.trajectoryLimits("touch-approach"), test.ShouldResemble, TrajectoryLimits{MaxJointDelta: math.Pi})
+	test.That(t, cfg.trajectoryLimits("pour-tilt").MaxStepL2, test.ShouldEqual, zeroPourProfile.maxStepL2())
+	test.That(t, cfg.trajectoryLimits("bottle-to-cup-align").MaxStepL2, test.ShouldEqual, zeroPourProfile.maxAlignL2())
+
+	cfg.TrajectoryLimits = map[string]TrajectoryLimits{
+		"default":   {MaxTravel: 10},
+		"pour-tilt": {MaxJointDelta: 1},
+	}
+	test.That(t, cfg.trajectoryLimits("touch-approach"), test.ShouldResemble, TrajectoryLimits{MaxJointDelta: math.Pi, MaxTravel: 10})
+	test.That(t, cfg.trajectoryLimits("pour-tilt"), test.ShouldResemble, TrajectoryLimits{MaxJointDelta: 1})
+	// default doesn't drop the built in limits
+	align := cfg.trajectoryLimits("bottle-to-cup-align")
+	test.That(t, align.MaxStepL2, test.ShouldEqual, zeroPourProfile.maxAlignL2())
+	test.That(t, align.MaxTravel, test.ShouldEqual, 10)
+	test.That(t, cfg.trajectoryLimits("pour-return").NoWristFlip, test.ShouldBeTrue)
+
+	tl := TrajectoryLimits{Workspace: []WorkspaceBox{{Min: r3.Vector{X: 1}}}}
+	test.That(t, tl.Validate("trajectory_limits.x"), test.ShouldNotBeNil)
+}
+
+func TestSimTrajectoryLimits(t *testing.T) {
+	ctx := context.Background()
+	vc, cart := newSimVinoCart(t)
+	oldPlanDir := planDir
+	planDir = t.TempDir()
+	t.Cleanup(func() { planDir = oldPlanDir })
+
+	everywhere := WorkspaceBox{Min: r3.Vector{X: -5000, Y: -5000, Z: -5000}, Max: r3.Vector{X: 5000, Y: 5000, Z: 5000}}
+	nowhere := WorkspaceBox{Min: r3.Vector{X: 5000, Y: 5000, Z: 5000}, Max: r3.Vector{X: 5001, Y: 5001, Z: 5001}}
+	vc.conf.TrajectoryLimits = map[string]TrajectoryLimits{
+		"ok":  {Workspace: []WorkspaceBox{everywhere}},
+		"bad": {Workspace: []WorkspaceBox{nowhere}},
+	}
+
+	test.That(t, vc.moveArm(ctx, cart.BottleArm, "ok", sim.BottleArmPour), test.ShouldBeNil)
+
+	err := vc.moveArm(ctx, cart.BottleArm, "bad", sim.BottleArmHome)
+	test.That(t, err, test.ShouldNotBeNil)
+	test.That(t, err.Error(), test.ShouldContainSubstring, "leaves the workspace")
+	joints, err := cart.BottleArm.JointPositions(ctx, nil)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, joints, test.ShouldResemble, sim.BottleArmPour)
+
+	files, err := os.ReadDir(planDir)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, len(files), test.ShouldEqual, 1)
+}
+
+func TestSimAlignReplansBadTrajectory(t *testing.T) {
+	ctx := context.Background()
+	vc, cart := newSimVinoCart(t)
+	oldPlanDir := planDir
+	planDir = t.TempDir()
+	t.Cleanup(func() { planDir = oldPlanDir })
+	putCup(t, cart, 450, 0)
+
+	vc.conf.TrajectoryLimits = map[string]TrajectoryLimits{"bottle-to-cup-align": {MaxTravel: 1e-9}}
+	err := vc.FullDemo(ctx)
+	test.That(t, err, test.ShouldNotBeNil)
+	test.That(t, err.Error(), test.ShouldContainSubstring, "bad trajectory")
+
+	// a new plan for every try, not the rejected one again
+	tries := vc.conf.pourProfile().alignTries()
+	test.That(t, vc.metrics.planDuration.get([]string{"bottle-to-cup-align", outcomeSuccess}).count, test.ShouldEqual, tries)
+	test.That(t, vc.metrics.alignRetries.get(nil).value, test.ShouldEqual, tries-1)
+
+	// timed even though it failed
+	_, ok := lastCycle(t, vc).StageMs["alignment"]
+	test.That(t, ok, test.ShouldBeTrue)
+}
+
+// travelArm remembers every direct move that took it anywhere.
+type travelArm struct {
+	arm.Arm
+
+	lock  sync.Mutex
+	moved []string
+}
+
+func (ta *travelArm) MoveToJointPositions(ctx context.Context, positions []referenceframe.Input, extra map[string]interface{}) error {
+	ta.check(ctx, [][]referenceframe.Input{positions})
+	return ta.Arm.MoveToJointPositions(ctx, positions, extra)
+}
+
+func (ta *travelArm) MoveThroughJointPositions(ctx context.Context, positions [][]referenceframe.Input, opts *arm.MoveOptions, extra map[string]interface{}) error {
+	ta.check(ctx, positions)
+	return ta.Arm.MoveThroughJointPositions(ctx, positions, opts, extra)
+}
+
+func (ta *travelArm) check(ctx context.Context, positions [][]referenceframe.Input) {
+	start, err := ta.Arm.JointPositions(ctx, nil)
+	if err != nil {
+		return
+	}
+	travel := 0.0
+	for _, p := range positions {
+		travel += referenceframe.InputsL2Distance(start, p)
+		start = p
+	}
+	if travel > 1e-6 {
+		ta.lock.Lock()
+		defer ta.lock.Unlock()
+		ta.moved = append(ta.moved, ta.Name().ShortName())
+	}
+}
+
+func TestSimEveryDirectMoveValidated(t *testing.T) {
+	ctx := context.Background()
+	vc, cart := newSimVinoCart(t)
+	oldPlanDir := planDir
+	planDir = t.TempDir()
+	t.Cleanup(func() { planDir = oldPlanDir })
+	putCup(t, cart, 450, 0)
+
+	cupArm := &travelArm{Arm: vc.c.Arm}
+	bottleArm := &travelArm{Arm: vc.c.BottleArm}
+	vc.c.Arm, vc.c.BottleArm = cupArm, bottleArm
+	// per move speeds, so position switches are moved to directly too
+	vc.setSpeedControl(speedControlAuto)
+	// nothing that goes anywhere passes
+	vc.conf.TrajectoryLimits = map[string]TrajectoryLimits{"default": {MaxTravel: 1e-9}}
+
+	for _, f := range []func(context.Context) error{
+		func(ctx context.Context) error { return vc.DoAll(ctx, "touch", "prep") },
+		vc.FullDemo,
+		vc.Resume,
+	} {
+		test.That(t, f(ctx), test.ShouldNotBeNil)
+	}
+	cart.Gripper.SetHolding(true)
+	test.That(t, vc.PourPrep(ctx), test.ShouldNotBeNil)
+
+	test.That(t, cupArm.moved, test.ShouldBeEmpty)
+	test.That(t, bottleArm.moved, test.ShouldBeEmpty)
+
+	files, err := os.ReadDir(planDir)
+	test.That(t, err, test.ShouldBeNil)
+	dumps := 0
+	for _, f := range files {
+		if strings.HasPrefix(f.Name(), "trajectory-bad-") {
+			dumps++
+		}
+	}
+	test.That(t, dumps, test.ShouldBeGreaterThan, 0)
+}
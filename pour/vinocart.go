@@ -3,11 +3,11 @@ package pour
 import (
 	"context"
 	"embed"
+	"errors"
 	"fmt"
 	"image"
 	"image/png"
 	"io/fs"
-	"net/http"
 	"os"
 	"path/filepath"
 	"sync"
@@ -23,6 +23,7 @@ import (
 	"go.viam.com/rdk/components/camera"
 	toggleswitch "go.viam.com/rdk/components/switch"
 	"go.viam.com/rdk/logging"
+	"go.viam.com/rdk/motionplan"
 	"go.viam.com/rdk/motionplan/armplanning"
 	"go.viam.com/rdk/referenceframe"
 	"go.viam.com/rdk/resource"
@@ -43,7 +44,6 @@ var vinowebStaticFS embed.FS
 
 const bottleName = "bottle-top"
 const cupTopName = "cup-top"
-const gripperToCupCenterHack float64 = -35
 
 // planDir is where bad plan debug files are written.
 //
@@ -61,6 +61,13 @@ var planDir = func() string {
 var VinoCartModel = NamespaceFamily.WithModel("vinocart")
 var noObjects = fmt.Errorf("no objects")
 
+// returned by the loop's waits once it's been paused or stopped, the second
+// after a cycle that finished before the area was clear
+var (
+	errLoopNotRunning          = fmt.Errorf("loop isn't running")
+	errCycleDoneLoopNotRunning = fmt.Errorf("loop isn't running, area not clear yet")
+)
+
 func init() {
 	resource.RegisterService(generic.API, VinoCartModel, resource.Registration[resource.Resource, *Config]{Constructor: newVinoCart})
 }
@@ -96,79 +103,95 @@ func newVinoCart(ctx context.Context, deps resource.Dependencies, conf resource.
 	if err != nil {
 		return nil, err
 	}
+	g.name = conf.ResourceName()
 
 	logger.Info("the pouring module has been constructed")
 	return g, nil
 }
 
+// NewVinoCart builds a VinoCart from components. client and viamClient may be nil,
+// then nothing is uploaded to the cloud.
 func NewVinoCart(ctx context.Context, conf *Config, c *Pour1Components, client robot.Robot, viamClient *app.ViamClient, logger logging.Logger) (*VinoCart, error) {
+	var dataClient *app.DataClient
+	if viamClient != nil {
+		dataClient = viamClient.DataClient()
+	}
+
+	// our own copy, so the caller's Motion isn't swapped for the timed one
+	cc := *c
+	c = &cc
+
 	vc := &VinoCart{
 		conf:        conf,
+		baseConf:    conf,
+		recipe:      conf.DefaultRecipe,
 		c:           c,
 		robotClient: client,
 		viamClient:  viamClient,
 		logger:      logger,
+
+		speedMultiplierNow: conf.speedMultiplier(),
+		speedControlKind:   conf.speedControl(),
+		speedControls:      map[string]speedControl{},
+
 		pourInspector: &pourInsepctor{
 			c.GlassFullnessService,
-			viamClient.DataClient(),
+			dataClient,
 			c.Cam.Name(),
 			logger,
 		},
 	}
 
-	vc.bottleTop = referenceframe.NewLinkInFrame(
-		vc.conf.BottleGripper,
-		spatialmath.NewPose(r3.Vector{X: vc.conf.BottleHeight - 70, Y: -7, Z: 0}, &spatialmath.OrientationVectorDegrees{OX: 1}),
-		bottleName,
-		nil,
-	)
+	vc.applyRecipeLocked()
 
-	vc.cupTop = referenceframe.NewLinkInFrame(
-		vc.conf.GripperName,
-		spatialmath.NewPose(
-			r3.Vector{X: vc.conf.cupGripHeightOffset(), Y: -75, Z: -15},
-			&spatialmath.OrientationVectorDegrees{OX: 1},
-		),
-		cupTopName,
-		nil,
-	)
+	vc.metrics = newVinoMetrics()
+	vc.plans = newPlanRecorder(conf.PlanRecordDir, conf.PlanRecordLimit, logger)
+	c.Motion = &timedMotion{Service: c.Motion, metrics: vc.metrics, recordMove: vc.recordMove}
 
-	vc.pourExtraFrames = []*referenceframe.LinkInFrame{vc.bottleTop}
+	vc.jobs = newJobManager(vc.getStatus)
+	vc.ops = newOpTracker()
+	vc.journal = newJournal(conf.JournalPath)
+	vc.tiltCache = newTiltCache(conf.TiltCachePath)
+	vc.loop = newLoopControl()
 
 	if conf.Loop {
-		vc.status = "starting"
-		vc.loopWaitGroup.Add(1)
-		cancelCtx, cancel := context.WithCancel(context.Background())
-		vc.loopCancel = cancel
-		go vc.run(cancelCtx)
+		vc.state = newCycleStateMachine(StateStarting, logger)
+		err := vc.StartLoop()
+		if err != nil {
+			return nil, err
+		}
 	} else {
-		vc.status = "manual mode"
+		vc.state = newCycleStateMachine(StateManual, logger)
 	}
-
 	realFS, err := fs.Sub(vinowebStaticFS, "vinoweb/dist")
 	if err != nil {
 		return nil, err
 	}
 
-	_, vc.server, err = vmodutils.PrepInModuleServer(realFS, logger.Sublogger("accesslog"), nil)
+	mux, server, err := vmodutils.PrepInModuleServer(realFS, logger.Sublogger("accesslog"), nil)
 	if err != nil {
 		return nil, err
 	}
-	go func() {
-		vc.server.Addr = ":9999"
-		vc.logger.Infof("web listening on %s", vc.server.Addr)
-		vc.server.ListenAndServe()
-	}()
+	mux.Handle("/metrics", vc.metrics)
+	vc.web = newWebServer(server.Handler, logger)
+	vc.web.start(conf.Web)
 
 	return vc, nil
 }
 
 type VinoCart struct {
-	resource.AlwaysRebuild
-
 	name   resource.Name
 	logger logging.Logger
-	conf   *Config
+
+	// conf is baseConf with the active recipe, it only changes between cycles,
+	// see Reconfigure and prepConf. Commands that can run during a cycle read
+	// it through currentConf or hold confLock for reading.
+	confLock         sync.RWMutex
+	conf             *Config
+	baseConf         *Config
+	pendingConf      *Config
+	pendingPositions map[string]StagePositions
+	recipe           string
 
 	robotClient robot.Robot
 	viamClient  *app.ViamClient
@@ -178,19 +201,74 @@ type VinoCart struct {
 	bottleTop       *referenceframe.LinkInFrame
 	cupTop          *referenceframe.LinkInFrame
 	pourExtraFrames []*referenceframe.LinkInFrame
-	pourWorldState  *referenceframe.WorldState
+	obstacles       []*referenceframe.GeometriesInFrame // from config, in every motion request
 
-	loopCancel    context.CancelFunc
-	loopWaitGroup sync.WaitGroup
+	loop *loopControl
 
-	statusLock sync.Mutex
-	status     string
+	state *cycleStateMachine
+	jobs  *jobManager
+
+	stageLock sync.Mutex
+	lastStage stageRecord
+	poured    bool // the held cup has been poured into, see setPoured
+
+	queueLock  sync.Mutex
+	queueIndex int
+	queueCount int
+
+	journal   *journal
+	tiltCache *tiltCache
+	cycleLock sync.Mutex
+	cycle     *cycleRecord
+
+	metrics *vinoMetrics
+	plans   *planRecorder
+
+	moveRecordLock sync.Mutex
+	moveRecordFs   *referenceframe.FrameSystem
+
+	speedLock          sync.Mutex
+	speedMultiplierNow float64
+	speedControlKind   string
+	speedControls      map[string]speedControl // by arm name, filled in as each arm's speed is first set
+
+	ops *opTracker
 
 	latestPour    time.Time
 	pourInspector *pourInsepctor
 	cancelPour    context.CancelFunc
 
-	server *http.Server
+	web *webServer
+}
+
+// setupFrames makes the bottle-top and cup-top frames from the config.
+func (vc *VinoCart) setupFrames() {
+	vc.bottleTop = referenceframe.NewLinkInFrame(
+		vc.conf.BottleGripper,
+		spatialmath.NewPose(vc.conf.bottleTopOffset(), &spatialmath.OrientationVectorDegrees{OX: 1}),
+		bottleName,
+		nil,
+	)
+
+	vc.cupTop = referenceframe.NewLinkInFrame(
+		vc.conf.GripperName,
+		spatialmath.NewPose(
+			vc.conf.cupTopOffset(),
+			&spatialmath.OrientationVectorDegrees{OX: 1},
+		),
+		cupTopName,
+		nil,
+	)
+
+	vc.pourExtraFrames = []*referenceframe.LinkInFrame{vc.bottleTop}
+}
+
+// dataClient is nil when not connected to app.
+func (vc *VinoCart) dataClient() *app.DataClient {
+	if vc.viamClient == nil {
+		return nil
+	}
+	return vc.viamClient.DataClient()
 }
 
 func (vc *VinoCart) Name() resource.Name {
@@ -198,9 +276,11 @@ func (vc *VinoCart) Name() resource.Name {
 }
 
 func (vc *VinoCart) Close(ctx context.Context) error {
-	if vc.loopCancel != nil {
-		vc.loopCancel()
-		vc.loopWaitGroup.Wait()
+	vc.jobs.cancelAll()
+
+	err := vc.StopLoop()
+	if err != nil {
+		vc.logger.Warnf("error stopping loop: %v", err)
 	}
 
 	var viamClientErr error
@@ -208,124 +288,359 @@ func (vc *VinoCart) Close(ctx context.Context) error {
 		viamClientErr = vc.viamClient.Close()
 	}
 
-	return multierr.Combine(vc.robotClient.Close(ctx), vc.server.Close(), viamClientErr)
+	var robotClientErr error
+	if vc.robotClient != nil {
+		robotClientErr = vc.robotClient.Close(ctx)
+	}
+
+	vc.plans.close()
+
+	return multierr.Combine(robotClientErr, vc.web.stop(ctx), viamClientErr)
 }
 
 func (vc *VinoCart) DoCommand(ctx context.Context, cmd map[string]interface{}) (map[string]interface{}, error) {
 	if cmd["status"] == true {
-		return map[string]interface{}{"status": vc.getStatus()}, nil
+		return vc.statusMap(), nil
+	}
+
+	if v, ok := cmd["set_speed_multiplier"]; ok {
+		m, ok := v.(float64)
+		if !ok {
+			return nil, fmt.Errorf("set_speed_multiplier must be a number")
+		}
+		if err := vc.SetSpeedMultiplier(m); err != nil {
+			return nil, err
+		}
+		return map[string]interface{}{"speed_multiplier": m}, nil
 	}
 
 	if cmd["stop"] == true {
-		return nil, multierr.Combine(vc.c.Arm.Stop(ctx, nil), vc.c.BottleArm.Stop(ctx, nil))
+		reason, ok := cmd["reason"].(string)
+		if !ok || reason == "" {
+			reason = "stop command"
+		}
+		return nil, vc.Abort(ctx, reason)
 	}
 
-	if vc.loopCancel != nil {
-		return nil, fmt.Errorf("in loop mode, can't do anything but get status")
+	if cmd["clear_fault"] == true {
+		return nil, vc.ClearFault()
 	}
 
-	defer func() {
-		vc.setStatus("manual mode")
-	}()
+	if id, ok := cmd["job_status"].(string); ok {
+		return vc.jobs.status(id)
+	}
+
+	if h, ok := cmd["history"]; ok {
+		hm, ok := h.(map[string]interface{})
+		if !ok {
+			hm = map[string]interface{}{}
+		}
+		return vc.history(hm)
+	}
+
+	if c, ok := cmd["calibrate"]; ok {
+		cm, ok := c.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("calibrate must be a map")
+		}
+		return vc.Calibrate(ctx, cm)
+	}
+
+	if cmd["describe_positions"] == true {
+		return vc.currentConf().describePositions(), nil
+	}
+
+	if cmd["world_state"] == true {
+		cups, _ := cmd["cups"].(bool)
+		return vc.describeWorldState(ctx, cups)
+	}
+
+	if cmd["clear_tilt_cache"] == true {
+		n, err := vc.tiltCache.clear()
+		if err != nil {
+			return nil, err
+		}
+		return map[string]interface{}{"cleared": n}, nil
+	}
+
+	if cmd["dry_run"] == true {
+		profile, err := vc.pourProfile(cmd)
+		if err != nil {
+			return nil, err
+		}
+		return vc.dryRun(ctx, profile)
+	}
+
+	if cmd["list_jobs"] == true {
+		return map[string]interface{}{"jobs": vc.jobs.list()}, nil
+	}
+
+	if id, ok := cmd["cancel_job"].(string); ok {
+		return nil, vc.jobs.cancelJob(id)
+	}
+
+	if cmd["start_loop"] == true {
+		return nil, vc.StartLoop()
+	}
+
+	if cmd["pause_loop"] == true {
+		return nil, vc.PauseLoop()
+	}
+
+	if cmd["resume_loop"] == true {
+		return nil, vc.ResumeLoop()
+	}
+
+	if cmd["stop_loop"] == true {
+		return nil, vc.StopLoop()
+	}
+
+	if vc.loop.blocksManual() {
+		return nil, fmt.Errorf("loop is %s, pause_loop or stop_loop first", vc.loop.get())
+	}
+
+	name, f, err := vc.motionCommand(cmd)
+	if err != nil {
+		return nil, err
+	}
+	if f != nil {
+		recipe, _ := cmd["recipe"].(string)
+		if err := vc.checkRecipe(recipe); err != nil {
+			return nil, err
+		}
+		return vc.runMotionCommand(ctx, name, recipe, f, cmd["blocking"] == true)
+	}
 
+	if cmd["under-pour"] == true {
+		return nil, vc.pourInspector.labelPour(ctx, vc.latestPour, underPour, vc.currentConf().DataCollection)
+	}
+
+	if cmd["good-pour"] == true {
+		return nil, vc.pourInspector.labelPour(ctx, vc.latestPour, goodPour, vc.currentConf().DataCollection)
+	}
+
+	if cmd["over-pour"] == true {
+		return nil, vc.pourInspector.labelPour(ctx, vc.latestPour, overPour, vc.currentConf().DataCollection)
+	}
+
+	if cmd["stop-pour"] == true {
+		return nil, vc.CancelPour()
+	}
+
+	return nil, fmt.Errorf("need a command")
+}
+
+// motionCommand finds the arm moving command in cmd, if there is one.
+func (vc *VinoCart) motionCommand(cmd map[string]interface{}) (string, func(context.Context) error, error) {
 	if cmd["reset"] == true {
-		return nil, vc.Reset(ctx)
+		return "reset", vc.Reset, nil
 	}
 
 	if cmd["touch"] == true {
-		return nil, vc.Touch(ctx)
+		return "touch", vc.Touch, nil
 	}
 
 	if cmd["pour-prep"] == true {
-		return nil, vc.PourPrep(ctx)
+		return "pour-prep", vc.PourPrep, nil
 	}
 
 	if cmd["pour"] == true {
-		return nil, vc.Pour(ctx)
+		// the recipe's profile isn't known until the job starts, check the override now anyway
+		if _, err := vc.pourProfile(cmd); err != nil {
+			return "", nil, err
+		}
+		return "pour", func(ctx context.Context) error {
+			profile, err := vc.pourProfile(cmd)
+			if err != nil {
+				return err
+			}
+			return vc.pour(ctx, profile)
+		}, nil
 	}
 
 	if cmd["put-back"] == true {
-		return nil, vc.PutBack(ctx)
+		return "put-back", vc.PutBack, nil
 	}
 
 	if cmd["demo"] == true {
-		return nil, vc.FullDemo(ctx)
+		return "demo", vc.FullDemo, nil
+	}
+
+	if cmd["resume"] == true {
+		return "resume", vc.Resume, nil
 	}
+
 	if cmd["test_position"] != nil {
 		positionCmd, ok := cmd["test_position"].(map[string]interface{})
 		if !ok {
-			return nil, fmt.Errorf("test_position must be a map")
+			return "", nil, fmt.Errorf("test_position must be a map")
 		}
 
 		stage, ok := positionCmd["stage"].(string)
 		if !ok {
-			return nil, fmt.Errorf("test_position.stage must be a string")
+			return "", nil, fmt.Errorf("test_position.stage must be a string")
 		}
 
 		step, ok := positionCmd["step"].(string)
 		if !ok {
-			return nil, fmt.Errorf("test_position.step must be a string")
+			return "", nil, fmt.Errorf("test_position.step must be a string")
 		}
 
-		return nil, vc.doAll(ctx, stage, step, 50)
+		return "test_position", func(ctx context.Context) error {
+			return vc.doAll(ctx, stage, step, 50)
+		}, nil
 	}
 
-	if cmd["under-pour"] == true {
-		return nil, vc.pourInspector.labelPour(ctx, vc.latestPour, underPour)
-	}
+	return "", nil, nil
+}
 
-	if cmd["good-pour"] == true {
-		return nil, vc.pourInspector.labelPour(ctx, vc.latestPour, goodPour)
+// runMotionCommand starts f as a background job using recipe, "" for the default, and returns its id.
+// With blocking set it waits for the job like DoCommand used to.
+func (vc *VinoCart) runMotionCommand(ctx context.Context, name, recipe string, f func(context.Context) error, blocking bool) (map[string]interface{}, error) {
+	if err := vc.checkNotFaulted(); err != nil {
+		return nil, err
 	}
 
-	if cmd["over-pour"] == true {
-		return nil, vc.pourInspector.labelPour(ctx, vc.latestPour, overPour)
+	j, err := vc.jobs.start(name, func(ctx context.Context) error {
+		ctx, done := vc.ops.track(ctx)
+		defer done()
+		// an abort before track couldn't cancel us, but it's latched by now
+		if err := vc.checkNotFaulted(); err != nil {
+			return err
+		}
+
+		vc.prepConf(recipe)
+
+		err := f(ctx)
+		if err != nil {
+			vc.state.fail(err)
+		} else {
+			vc.setStatus(StateDone)
+		}
+		return err
+	})
+	if err != nil {
+		return nil, err
 	}
 
-	if cmd["stop-pour"] == true {
-		return nil, vc.CancelPour()
+	if blocking {
+		err = vc.jobs.wait(ctx, j)
+		if err != nil {
+			return nil, err
+		}
 	}
 
-	return nil, fmt.Errorf("need a command")
+	return map[string]interface{}{"job_id": j.id}, nil
+}
+
+func (vc *VinoCart) statusMap() map[string]interface{} {
+	m := vc.state.status()
+	m["last_completed_stage"] = vc.lastCompletedStage().stage.String()
+	if q := vc.cupQueueStatus(); q != nil {
+		m["cup_queue"] = q
+	}
+	m["loop"] = vc.loop.status()
+	m["speed_multiplier"] = vc.getSpeedMultiplier()
+	m["speed_control"] = vc.speedControlStatus()
+	m["recipe"] = vc.activeRecipe()
+	m["web"] = vc.web.status()
+	return m
 }
 
 func (vc *VinoCart) run(ctx context.Context) {
-	defer vc.loopWaitGroup.Done()
+	resumeNext, clearFirst := false, false
 	for ctx.Err() == nil {
-		vc.setStatus("standby")
-		err := vc.WaitForCupAndGo(ctx)
-		if err != nil {
+		vc.waitWhileFaulted(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+
+		vc.prepConf("")
+		vc.setStatus(StateStandby)
+
+		if vc.loop.get() != loopRunning {
+			if vc.loop.finishStopping() {
+				vc.loopStopped()
+				return
+			}
+			// manual commands can run while paused, so start over once resumed
+			if !vc.loop.holdIfPaused(ctx) {
+				return
+			}
+			continue
+		}
+
+		cycleCtx, done := vc.ops.track(ctx)
+		var err error
+		switch {
+		case clearFirst:
+			err = vc.waitForClearArea(cycleCtx, vc.loop.running)
+			clearFirst = err != nil
+		case resumeNext:
+			vc.logger.Infof("resuming after failure")
+			err = vc.waitForCupAndGo(cycleCtx, vc.Resume, vc.loop.running)
+			resumeNext = err == errLoopNotRunning
+		default:
+			err = vc.waitForCupAndGo(cycleCtx, vc.FullDemo, vc.loop.running)
+			resumeNext = err != nil && err != errLoopNotRunning && vc.conf.loopRecovery() == loopRecoveryResume
+		}
+		done()
+		if err == errCycleDoneLoopNotRunning {
+			// the cup is still there, so make sure it's gone before the next cycle
+			clearFirst = true
+			err = nil
+		}
+		if err != nil && err != errLoopNotRunning {
 			vc.logger.Errorf("go error in run: %v", err)
+			vc.state.fail(err)
 		}
 	}
 }
 
-func (vc *VinoCart) getStatus() string {
-	vc.statusLock.Lock()
-	defer vc.statusLock.Unlock()
-	return vc.status
+func (vc *VinoCart) getStatus() CycleState {
+	return vc.state.get()
 }
 
-func (vc *VinoCart) setStatus(s string) {
+func (vc *VinoCart) setStatus(s CycleState) {
 	vc.logger.Infof("setStatus: %v", s)
-	vc.statusLock.Lock()
-	defer vc.statusLock.Unlock()
-	vc.status = s
+	err := vc.state.transition(s)
+	if err != nil {
+		vc.logger.Errorf("setStatus: %v", err)
+	}
 }
 
 func (vc *VinoCart) WaitForCupAndGo(ctx context.Context) error {
+	return vc.waitForCupAndGo(ctx, vc.FullDemo, nil)
+}
+
+// waitForCupAndGo runs cycle until there's a cup, then waits for the area to be
+// clear. keepWaiting, if set, is checked each time around so the loop can pause.
+func (vc *VinoCart) waitForCupAndGo(ctx context.Context, cycle func(context.Context) error, keepWaiting func() bool) error {
 	for {
-		err := vc.FullDemo(ctx)
+		err := cycle(ctx)
 		if err == nil {
 			break
 		}
 		if err != noObjects {
 			return err
 		}
+		if keepWaiting != nil && !keepWaiting() {
+			return errLoopNotRunning
+		}
 		vc.logger.Infof("got %v, looping", err)
+		vc.applyPendingConf()
 	}
 
-	vc.setStatus("waiting")
+	err := vc.waitForClearArea(ctx, keepWaiting)
+	if err == errLoopNotRunning {
+		return errCycleDoneLoopNotRunning
+	}
+	return err
+}
+
+// waitForClearArea waits till FindCups doesn't see anything.
+func (vc *VinoCart) waitForClearArea(ctx context.Context, keepWaiting func() bool) error {
+	vc.setStatus(StateWaiting)
 
 	// need to wait till the area is clear
 	vc.logger.Infof("waiting for area to be clear")
@@ -343,11 +658,23 @@ func (vc *VinoCart) WaitForCupAndGo(ctx context.Context) error {
 		if len(objects) == 0 {
 			return nil
 		}
+		if keepWaiting != nil && !keepWaiting() {
+			return errLoopNotRunning
+		}
 	}
 }
 
-func (vc *VinoCart) FullDemo(ctx context.Context) error {
-	err := vc.Touch(ctx)
+func (vc *VinoCart) FullDemo(ctx context.Context) (err error) {
+	rec := vc.startCycle("full-demo")
+	defer func() {
+		vc.endCycle(rec, err)
+	}()
+
+	if vc.conf.MultiCup {
+		return vc.MultiCupDemo(ctx)
+	}
+
+	err = vc.Touch(ctx)
 	if err != nil {
 		return err
 	}
@@ -363,6 +690,7 @@ func (vc *VinoCart) FullDemo(ctx context.Context) error {
 }
 
 func (vc *VinoCart) Reset(ctx context.Context) error {
+	defer vc.timeStage("reset")()
 	defer func() {
 		if err := cleanupImages(dirnameForPour(vc.latestPour)); err != nil {
 			vc.logger.Errorf("failed to cleanup images: %v\n", err)
@@ -407,25 +735,8 @@ func (vc *VinoCart) Reset(ctx context.Context) error {
 	})
 
 	g.Go(func() error {
-		var err error
 		if bottleHoldingStatus.IsHoldingSomething {
-			err = vc.doAll(ctx, "reset", "right-holding-pre", 50)
-			if err != nil {
-				return err
-			}
-
-			err = vc.c.BottleGripper.Open(ctx, nil)
-			if err != nil {
-				return err
-			}
-
-			time.Sleep(time.Millisecond * 500)
-
-			err = vc.doAll(ctx, "reset", "right-holding-post", 50)
-			if err != nil {
-				return err
-			}
-
+			return vc.putDownBottle(ctx)
 		}
 		return nil
 	})
@@ -495,12 +806,9 @@ func (vc *VinoCart) checkPickQuality(ctx context.Context) error {
 		return err
 	}
 
-	if vc.viamClient != nil {
-		vc.logger.Infof("uploading image to dataset for cup pick quality")
-		err := saveImageToDataset(ctx, vc.c.Cam.Name(), prepped, vc.viamClient.DataClient(), "683f8952383a821481d9b5c9", nil)
-		if err != nil {
-			vc.logger.Warnf("can't saveCupImage: %v", err)
-		}
+	err = saveTrainingImage(ctx, vc.conf.DataCollection, streamPickQuality, vc.c.Cam.Name(), prepped, vc.dataClient())
+	if err != nil {
+		vc.logger.Warnf("can't saveCupImage: %v", err)
 	}
 
 	cs, err := vc.c.PickQualityService.Classifications(ctx, prepped, 1, nil)
@@ -519,6 +827,7 @@ func (vc *VinoCart) checkPickQuality(ctx context.Context) error {
 	}
 
 	// bad pick, move
+	vc.metrics.inc(vc.metrics.pickRejections)
 
 	err = vc.doAll(ctx, "touch", "bad-pick-a", 50)
 	if err != nil {
@@ -538,7 +847,7 @@ func (vc *VinoCart) checkPickQuality(ctx context.Context) error {
 	return fmt.Errorf("bad pick %v", cs[0])
 }
 
-func saveImageToDatasetFromCamera(ctx context.Context, cam camera.Camera, dataClient *app.DataClient, dataSetId string) error {
+func saveTrainingImageFromCamera(ctx context.Context, dc DataCollection, name string, cam camera.Camera, dataClient *app.DataClient) error {
 	imgs, _, err := cam.Images(ctx, nil, nil)
 	if err != nil {
 		return err
@@ -547,7 +856,7 @@ func saveImageToDatasetFromCamera(ctx context.Context, cam camera.Camera, dataCl
 	if err != nil {
 		return err
 	}
-	return saveImageToDataset(ctx, cam.Name(), i, dataClient, dataSetId, nil)
+	return saveTrainingImage(ctx, dc, name, cam.Name(), i, dataClient)
 }
 
 func saveImageToDataset(ctx context.Context, component resource.Name, img image.Image, dataClient *app.DataClient, dataSetId string, tags []string) error {
@@ -586,7 +895,7 @@ func saveImageToDataset(ctx context.Context, component resource.Name, img image.
 }
 
 func (vc *VinoCart) Touch(ctx context.Context) error {
-	vc.setStatus("looking")
+	vc.setStatus(StateLooking)
 
 	err := vc.Reset(ctx)
 	if err != nil {
@@ -612,96 +921,125 @@ func (vc *VinoCart) Touch(ctx context.Context) error {
 		return fmt.Errorf("too many objects %d", len(objects))
 	}
 
-	vc.setStatus("picking")
+	_, err = vc.touchCup(ctx, objects[0], nil, vc.conf.Handoff)
+	return err
+}
 
-	obj := objects[0]
+// touchCup picks up obj, treating others as obstacles. It returns the
+// orientation the gripper approached with.
+func (vc *VinoCart) touchCup(ctx context.Context, obj *viz.Object, others []*viz.Object, handoff bool) (*spatialmath.OrientationVectorDegrees, error) {
+	vc.setStatus(StatePicking)
+	vc.setPoured(false)
 
 	// -- setup world frame
 
-	obstacles := []*referenceframe.GeometriesInFrame{}
-	// Set a label on the cup geometry to avoid "unnamedWorldStateGeometry" collisions
-	obj.Geometry.SetLabel("cup")
-	obstacles = append(obstacles, referenceframe.NewGeometriesInFrame("world", []spatialmath.Geometry{obj.Geometry}))
-	vc.logger.Infof("add cup as obstacle %v", obj.Geometry)
-
-	worldState, err := referenceframe.NewWorldState(obstacles, nil)
+	worldState, err := vc.pickWorldState(obj, others)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	// -- approach
+	// -- approach, plan every choice and take the best
 
 	var o *spatialmath.OrientationVectorDegrees
 
-	choices := []*spatialmath.OrientationVectorDegrees{
-		{OX: 1, Theta: 180},
-		{OY: 1, Theta: 180},
-		{OX: .5, OY: 1, Theta: 180},
-		{OX: 1, OY: 1, Theta: 180},
-		{OX: 1, OY: -1, Theta: 180},
-		{OY: -1, Theta: 180},
-		{OX: -.5, OY: -1, Theta: 180},
-	}
+	choices := approachChoices
 
+	endApproach := vc.timeStage("approach")
+	candidates, err := vc.planApproaches(ctx, "touch-approach", nil, obj, others, choices)
+	if err != nil {
+		endApproach()
+		return nil, err
+	}
 	approaches := []*referenceframe.PoseInFrame{}
-
-	for _, tryO := range choices {
-		goToPose := vc.getApproachPoint(obj, 100, tryO)
-		approaches = append(approaches, goToPose)
-		vc.logger.Infof("trying to move to %v", goToPose.Pose())
-
-		_, err2 := vc.c.Motion.Move(
-			ctx,
-			motion.MoveReq{
-				ComponentName: vc.c.Gripper.Name().ShortName(),
-				Destination:   goToPose,
-				WorldState:    worldState,
-				Extra:         planTagExtra("touch-approach"),
-			},
-		)
-
-		if err2 != nil {
-			vc.logger.Debugf("error: %v", err2)
-		}
-
-		if err2 == nil {
-			err = nil
-			o = tryO
-			break
-		} else if err == nil {
-			err = err2
-		}
+	for _, ac := range candidates {
+		vc.logger.Infof("approach %v", ac)
+		approaches = append(approaches, ac.pose)
+	}
+	best, err := bestApproach(candidates)
+	if err == nil {
+		o = best.o
+		vc.logger.Infof("approaching with %v", o)
+		err = vc.moveArm(ctx, vc.c.Arm, "touch-approach", best.trajectory...)
 	}
+	endApproach()
 
-	if vc.conf.Handoff && err != nil {
+	if handoff && err != nil {
 
 		err2 := vc.handoffCupBottleToCupArm(ctx, worldState, approaches, choices, obj)
 		if err2 == nil {
-			return nil
+			return nil, nil
 		}
 
-		return multierr.Combine(err, err2)
+		return nil, multierr.Combine(err, err2)
 	}
 
 	if err != nil {
-		return err
+		return nil, err
 	}
 
+	vc.recordCycle(func(r *cycleRecord) {
+		r.ApproachOrientation = o
+	})
+
 	// ---- go to pick up
-	err = SetXarmSpeed(ctx, vc.c.Arm, 25, 25)
+	defer vc.timeStage("grab")()
+	err = vc.setArmSpeed(ctx, speedTouchPickup, both(25))
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	goToPose := vc.getApproachPoint(obj, gripperToCupCenterHack, o)
+	goToPose := vc.getApproachPoint(obj, vc.conf.gripperToCupCenter(), o)
 	vc.logger.Infof("going to move to %v", goToPose)
 
-	err = moveWithLinearConstraint(ctx, vc.c.Motion, vc.c.Gripper.Name(), goToPose, "touch-pickup")
+	pickupObstacles := append(append([]*referenceframe.GeometriesInFrame{}, vc.obstacles...), otherCupObstacles(others)...)
+	err = moveWithLinearConstraint(ctx, vc.c.Motion, vc.c.Gripper.Name(), goToPose, pickupObstacles, vc.moveExtra(vc.c.Arm, "touch-pickup"))
 	if err != nil {
-		return err
+		return nil, err
+	}
+
+	err = vc.GrabCup(ctx)
+	if err != nil {
+		return nil, err
 	}
 
-	return vc.GrabCup(ctx)
+	vc.markStageDone(ctx, stageTouch)
+	return o, nil
+}
+
+// approachChoices are the gripper orientations tried, in order, when approaching a cup.
+var approachChoices = []*spatialmath.OrientationVectorDegrees{
+	{OX: 1, Theta: 180},
+	{OY: 1, Theta: 180},
+	{OX: .5, OY: 1, Theta: 180},
+	{OX: 1, OY: 1, Theta: 180},
+	{OX: 1, OY: -1, Theta: 180},
+	{OY: -1, Theta: 180},
+	{OX: -.5, OY: -1, Theta: 180},
+}
+
+// pickWorldState has the cup being picked and all the others as obstacles.
+func (vc *VinoCart) pickWorldState(obj *viz.Object, others []*viz.Object) (*referenceframe.WorldState, error) {
+	return vc.worldState(vc.pickObstacles(obj, others)...)
+}
+
+func (vc *VinoCart) pickObstacles(obj *viz.Object, others []*viz.Object) []*referenceframe.GeometriesInFrame {
+	obstacles := []*referenceframe.GeometriesInFrame{}
+	// Set a label on the cup geometry to avoid "unnamedWorldStateGeometry" collisions
+	obj.Geometry.SetLabel("cup")
+	obstacles = append(obstacles, referenceframe.NewGeometriesInFrame("world", []spatialmath.Geometry{obj.Geometry}))
+	vc.logger.Infof("add cup as obstacle %v", obj.Geometry)
+
+	return append(obstacles, otherCupObstacles(others)...)
+}
+
+// otherCupObstacles are the cups not being moved, for moves that end at the one that is.
+func otherCupObstacles(others []*viz.Object) []*referenceframe.GeometriesInFrame {
+	obstacles := []*referenceframe.GeometriesInFrame{}
+	for idx, other := range others {
+		other.Geometry.SetLabel(fmt.Sprintf("other-cup-%d", idx))
+		obstacles = append(obstacles, referenceframe.NewGeometriesInFrame("world", []spatialmath.Geometry{other.Geometry}))
+	}
+	return obstacles
 }
 
 func (vc *VinoCart) handoffCupBottleToCupArm(ctx context.Context, worldState *referenceframe.WorldState, approaches []*referenceframe.PoseInFrame, choices []*spatialmath.OrientationVectorDegrees, obj *viz.Object) error {
@@ -714,7 +1052,7 @@ func (vc *VinoCart) handoffCupBottleToCupArm(ctx context.Context, worldState *re
 				ComponentName: vc.c.BottleGripper.Name().ShortName(),
 				Destination:   goToPose,
 				WorldState:    worldState,
-				Extra:         planTagExtra("handoff-approach"),
+				Extra:         vc.moveExtra(vc.c.BottleArm, "handoff-approach"),
 			},
 		)
 		if err != nil {
@@ -724,10 +1062,10 @@ func (vc *VinoCart) handoffCupBottleToCupArm(ctx context.Context, worldState *re
 
 		// we found a path!
 
-		goToPose = vc.getApproachPoint(obj, gripperToCupCenterHack, choices[idx])
+		goToPose = vc.getApproachPoint(obj, vc.conf.gripperToCupCenter(), choices[idx])
 		vc.logger.Infof("going to move (2) to %v", goToPose)
 
-		err = moveWithLinearConstraint(ctx, vc.c.Motion, vc.c.BottleGripper.Name(), goToPose, "handoff-pickup")
+		err = moveWithLinearConstraint(ctx, vc.c.Motion, vc.c.BottleGripper.Name(), goToPose, vc.obstacles, vc.moveExtra(vc.c.BottleArm, "handoff-pickup"))
 		if err != nil {
 			return err
 		}
@@ -742,7 +1080,7 @@ func (vc *VinoCart) handoffCupBottleToCupArm(ctx context.Context, worldState *re
 
 		// move to known spot
 		goToPose = vc.getApproachPoint(obj, 150, choices[idx])
-		err = moveWithLinearConstraint(ctx, vc.c.Motion, vc.c.BottleGripper.Name(), goToPose, "handoff-lift")
+		err = moveWithLinearConstraint(ctx, vc.c.Motion, vc.c.BottleGripper.Name(), goToPose, vc.obstacles, vc.moveExtra(vc.c.BottleArm, "handoff-lift"))
 		if err != nil {
 			return err
 		}
@@ -755,7 +1093,7 @@ func (vc *VinoCart) handoffCupBottleToCupArm(ctx context.Context, worldState *re
 
 		// backup
 		goToPose = vc.getApproachPoint(obj, 250, choices[idx])
-		err = moveWithLinearConstraint(ctx, vc.c.Motion, vc.c.BottleGripper.Name(), goToPose, "handoff-backup")
+		err = moveWithLinearConstraint(ctx, vc.c.Motion, vc.c.BottleGripper.Name(), goToPose, vc.obstacles, vc.moveExtra(vc.c.BottleArm, "handoff-backup"))
 		if err != nil {
 			return err
 		}
@@ -795,12 +1133,7 @@ func (vc *VinoCart) DoAll(ctx context.Context, stage, step string) error {
 }
 
 func (vc *VinoCart) doAll(ctx context.Context, stage, step string, speedAndAccelBothArm float64) error {
-	err := SetXarmSpeed(ctx, vc.c.Arm, speedAndAccelBothArm, speedAndAccelBothArm)
-	if err != nil {
-		return err
-	}
-
-	err = SetXarmSpeed(ctx, vc.c.BottleArm, speedAndAccelBothArm, speedAndAccelBothArm)
+	err := vc.setSpeeds(ctx, stage+"/"+step, both(speedAndAccelBothArm))
 	if err != nil {
 		return err
 	}
@@ -831,7 +1164,7 @@ func (vc *VinoCart) PourPrepGrab(ctx context.Context) error {
 	positions[0] -= utils.DegToRad(2)
 	vc.logger.Infof("pourPrepGrab hack: %v", positions[0])
 
-	err = vc.c.BottleArm.MoveToJointPositions(ctx, positions, nil)
+	err = vc.moveArm(ctx, vc.c.BottleArm, "pour-prep-grab", positions)
 	if err != nil {
 		return err
 	}
@@ -848,7 +1181,7 @@ func (vc *VinoCart) PourPrepGrab(ctx context.Context) error {
 	positions[0] = orig
 	positions[5] -= .3 // tilt bottle to increase friction
 
-	err = vc.c.BottleArm.MoveToJointPositions(ctx, positions, nil)
+	err = vc.moveArm(ctx, vc.c.BottleArm, "pour-prep-grab", positions)
 	if err != nil {
 		return err
 	}
@@ -860,8 +1193,26 @@ func (vc *VinoCart) PourPrepGrab(ctx context.Context) error {
 	return nil
 }
 
+// putDownBottle lets go of the bottle through the reset right-holding positions.
+func (vc *VinoCart) putDownBottle(ctx context.Context) error {
+	err := vc.doAll(ctx, "reset", "right-holding-pre", 50)
+	if err != nil {
+		return err
+	}
+
+	err = vc.c.BottleGripper.Open(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	time.Sleep(time.Millisecond * 500)
+
+	return vc.doAll(ctx, "reset", "right-holding-post", 50)
+}
+
 func (vc *VinoCart) PourPrep(ctx context.Context) error {
-	vc.setStatus("prepping")
+	vc.setStatus(StatePrepping)
+	defer vc.timeStage("pour_prep")()
 	holdingStatus, err := vc.c.Gripper.IsHoldingSomething(ctx, nil)
 	if err != nil {
 		return err
@@ -890,6 +1241,7 @@ func (vc *VinoCart) PourPrep(ctx context.Context) error {
 		return err
 	}
 
+	vc.markStageDone(ctx, stagePourPrep)
 	return nil
 }
 
@@ -899,7 +1251,7 @@ func (vc *VinoCart) goTo(ctx context.Context, poss ...toggleswitch.Switch) error
 	}
 
 	if len(poss) == 1 {
-		return poss[0].SetPosition(ctx, 2, nil)
+		return vc.setPosition(ctx, poss[0])
 	}
 
 	var errorLock sync.Mutex
@@ -911,7 +1263,7 @@ func (vc *VinoCart) goTo(ctx context.Context, poss ...toggleswitch.Switch) error
 		wg.Add(1)
 		go func(pp toggleswitch.Switch) {
 			defer wg.Done()
-			err := pp.SetPosition(ctx, 2, nil)
+			err := vc.setPosition(ctx, pp)
 			if err != nil {
 				errorLock.Lock()
 				errors = append(errors, err)
@@ -940,12 +1292,18 @@ func (vc *VinoCart) moveToCurrentXYAtCupHeight(ctx context.Context) error {
 			Z: vc.conf.CupHeight - vc.conf.cupGripHeightOffset(),
 		}, cur.Pose().Orientation()))
 
+	worldState, err := vc.worldState()
+	if err != nil {
+		return err
+	}
+
 	_, err = vc.c.Motion.Move(
 		ctx,
 		motion.MoveReq{
 			ComponentName: vc.conf.GripperName,
 			Destination:   cur,
-			Extra:         planTagExtra("descend-cup-height"),
+			WorldState:    worldState,
+			Extra:         vc.moveExtra(vc.c.Arm, "descend-cup-height"),
 		},
 	)
 	return err
@@ -1009,7 +1367,11 @@ func (vc *VinoCart) GetGlassPourCamImage(ctx context.Context, box *image.Rectang
 }
 
 func (vc *VinoCart) Pour(ctx context.Context) error {
-	vc.setStatus("pouring")
+	return vc.pour(ctx, vc.conf.pourProfile())
+}
+
+func (vc *VinoCart) pour(ctx context.Context, profile PourProfile) error {
+	vc.setStatus(StatePouring)
 
 	isHoldingCup, err := vc.c.Gripper.IsHoldingSomething(ctx, nil)
 	if err != nil {
@@ -1027,11 +1389,7 @@ func (vc *VinoCart) Pour(ctx context.Context) error {
 		return fmt.Errorf("bottle gripper %v is not holding bottle", vc.c.BottleGripper.Name())
 	}
 
-	err = SetXarmSpeed(ctx, vc.c.Arm, 50, 50)
-	if err != nil {
-		return err
-	}
-	err = SetXarmSpeed(ctx, vc.c.BottleArm, 50, 50)
+	err = vc.setSpeeds(ctx, "pour/prep", both(50))
 	if err != nil {
 		return err
 	}
@@ -1055,10 +1413,7 @@ func (vc *VinoCart) Pour(ctx context.Context) error {
 	if err != nil {
 		return fmt.Errorf("failed to get bottle-top pose: %w", err)
 	}
-	gapOffset := r3.Vector{Z: 50}
-	alignTarget := referenceframe.NewPoseInFrame("world",
-		spatialmath.NewPose(cupTarget.Pose().Point().Add(gapOffset), bottleTopNow.Pose().Orientation()),
-	)
+	alignTarget := bottleAlignTarget(cupTarget.Pose(), bottleTopNow.Pose(), profile.bottleGap())
 	vc.logger.Infof("aligning bottle-top to: %v", alignTarget.Pose())
 
 	alignFs, err := touch.FrameSystemWithSomeParts(ctx, vc.c.Rfs, []string{vc.conf.BottleArm, vc.conf.BottleGripper}, vc.pourExtraFrames)
@@ -1069,6 +1424,10 @@ func (vc *VinoCart) Pour(ctx context.Context) error {
 	if err != nil {
 		return err
 	}
+	alignWorld, err := vc.planWorldState(ctx, alignFs)
+	if err != nil {
+		return err
+	}
 	alignReq := &armplanning.PlanRequest{
 		FrameSystem: alignFs,
 		Goals: []*armplanning.PlanState{
@@ -1077,62 +1436,14 @@ func (vc *VinoCart) Pour(ctx context.Context) error {
 		StartState: armplanning.NewPlanState(nil, referenceframe.FrameSystemInputs{
 			vc.conf.BottleArm: alignJoints,
 		}),
+		WorldState: alignWorld,
 	}
-	alignPlan, _, err := armplanning.PlanMotion(ctx, vc.logger, alignReq)
+	err = vc.alignBottle(ctx, profile, alignReq, alignJoints)
 	if err != nil {
-		return fmt.Errorf("failed to plan bottle alignment: %w", err)
-	}
-	for i, step := range alignPlan.Trajectory() {
-		vc.logger.Infof("[bottle-to-cup-align] plan step %d: %v", i, step[vc.conf.BottleArm])
-	}
-	if len(alignPlan.Trajectory()) != 2 {
-		return fmt.Errorf("[bottle-to-cup-align] unexpected trajectory length (%d)", len(alignPlan.Trajectory()))
-	}
-	const maxAlignTries = 5
-	var lastErr error
-	for try := 1; try <= maxAlignTries; try++ {
-		alignGoalJoints := alignPlan.Trajectory()[1][vc.conf.BottleArm]
-		alignL2 := referenceframe.InputsL2Distance(alignJoints, alignGoalJoints)
-		vc.logger.Infof("[bottle-to-cup-align][try %d] InputsL2Distance: %v", try, alignL2)
-		if alignL2 > 1.3 {
-			if mkErr := os.MkdirAll(planDir, 0o755); mkErr != nil {
-				vc.logger.Errorf("[bottle-to-cup-align][try %d] failed to create %s: %v", try, planDir, mkErr)
-			}
-			fn := fmt.Sprintf("%s/align-plan-bad-try-%d-%d.json", planDir, try, time.Now().Unix())
-			debugErr := alignReq.WriteToFile(fn)
-			if debugErr != nil {
-				vc.logger.Errorf("[bottle-to-cup-align][try %d] failed to write debug: %v", try, debugErr)
-			} else {
-				vc.logger.Warnf("[bottle-to-cup-align][try %d] debug written to %s", try, fn)
-			}
-			lastErr = fmt.Errorf("[bottle-to-cup-align][try %d] pos too far: %v", try, alignL2)
-			// On all but the final try, replan
-			if try < maxAlignTries {
-				vc.logger.Warnf("[bottle-to-cup-align][try %d] L2 too high, replanning...", try)
-				alignPlan, _, err = armplanning.PlanMotion(ctx, vc.logger, alignReq)
-				if err != nil {
-					return fmt.Errorf("failed to replan bottle alignment on try %d: %w", try, err)
-				}
-				continue
-			} else {
-				return lastErr
-			}
-		}
-		vc.logger.Infof("[bottle-to-cup-align][try %d] moving bottle arm to align with cup target", try)
-		err = vc.c.BottleArm.MoveToJointPositions(ctx, alignGoalJoints, nil)
-		if err != nil {
-			lastErr = fmt.Errorf("failed to align bottle to cup on try %d: %w", try, err)
-			continue
-		}
-		// Success!
-		lastErr = nil
-		break
-	}
-	if lastErr != nil {
-		return lastErr
+		return err
 	}
 
-	pp, err := vc.SetupPourPositions(ctx)
+	pp, err := vc.setupPourPositions(ctx, profile)
 	if err != nil {
 		return err
 	}
@@ -1153,7 +1464,7 @@ func (vc *VinoCart) Pour(ctx context.Context) error {
 	}
 	var pd *pourDetector
 
-	totalTime := 15 * time.Second
+	totalTime := profile.totalTime()
 	markedDifferent := false
 
 	pourContext, cancelPour := context.WithCancel(ctx)
@@ -1161,12 +1472,13 @@ func (vc *VinoCart) Pour(ctx context.Context) error {
 	wg := sync.WaitGroup{}
 	wg.Add(1)
 
-	if vc.viamClient != nil && vc.c.GlassPourCam != nil {
+	dc := vc.conf.DataCollection
+	if vc.c.GlassPourCam != nil && !dc.GlassCam.Disabled && (vc.viamClient != nil || dc.LocalOnly) {
 		vc.logger.Infof("uploading image to dataset for cup finding")
 		wg.Add(1)
 		go func() {
 			defer wg.Done()
-			err := saveImageToDatasetFromCamera(context.Background(), vc.c.GlassPourCam, vc.viamClient.DataClient(), "683d1210c83b3f3823ec70ff")
+			err := saveTrainingImageFromCamera(context.Background(), dc, streamGlassCam, vc.c.GlassPourCam, vc.dataClient())
 			if err != nil {
 				vc.logger.Errorf("error saving cup cam to data set: %v", err)
 			}
@@ -1192,14 +1504,21 @@ func (vc *VinoCart) Pour(ctx context.Context) error {
 		cancelPour()
 		wg.Wait() // this goes back down
 
-		SetXarmSpeedLog(ctx, vc.c.BottleArm, 50, 50, vc.logger)
+		vc.setBottleArmSpeedLog(ctx, speedDefault, both(50))
 
 		err := vc.doAll(ctx, "pour", "finish", 50)
 		if err != nil {
 			vc.logger.Infof("error in pour cleanup: %v", err)
+			return
 		}
+
+		// once the bottle has tilted, count it as poured even if the loop
+		// below failed, so resume doesn't pour into the same cup twice
+		vc.markStageDone(ctx, stagePour)
 	}()
 
+	endPourLoop := vc.timeStage("pour_loop")
+	stopReason := stopReasonTimeout
 	for time.Since(start) < totalTime {
 		loopStart := time.Now()
 
@@ -1214,6 +1533,7 @@ func (vc *VinoCart) Pour(ctx context.Context) error {
 				return err
 			}
 			if isGoodPour {
+				stopReason = stopReasonMLModel
 				break
 			}
 		} else {
@@ -1221,26 +1541,112 @@ func (vc *VinoCart) Pour(ctx context.Context) error {
 				pd = newPourDetector(img)
 			} else {
 				delta, _ := pd.differentDebug(img)
+				vc.recordCycle(func(r *cycleRecord) {
+					r.PourDeltas = append(r.PourDeltas, delta)
+				})
 				deltaMax := vc.conf.glassPourMotionThreshold()
 				vc.logger.Infof("fn: %v delta: %0.2f (%f)", fn, delta, deltaMax)
 				if delta >= deltaMax && !markedDifferent {
 					vc.logger.Infof(" **** motion detected *** ")
 					markedDifferent = true
-					totalTime = time.Since(start) + time.Second
+					stopReason = stopReasonMotion
+					totalTime = time.Since(start) + profile.afterMotion()
 				}
 			}
 		}
 
-		sleepTime := (100 * time.Millisecond) - time.Since(loopStart)
+		sleepTime := profile.sampleInterval() - time.Since(loopStart)
 		vc.logger.Debugf("going to sleep for %v", sleepTime)
 		time.Sleep(sleepTime)
 		loopNumber++
 	}
+	endPourLoop()
+
+	if stopReason == stopReasonTimeout && pourContext.Err() != nil {
+		stopReason = stopReasonCanceled
+	}
+	vc.logger.Infof("pour stopped: %s", stopReason)
+	vc.metrics.inc(vc.metrics.pourStops, stopReason)
+	vc.recordCycle(func(r *cycleRecord) {
+		r.StopReason = stopReason
+	})
 
 	// cleanup done in defer above
 	return nil
 }
 
+// alignBottle plans and moves the bottle arm from alignJoints to line the bottle
+// up with the cup, replanning when a plan is no good.
+func (vc *VinoCart) alignBottle(ctx context.Context, profile PourProfile, alignReq *armplanning.PlanRequest, alignJoints []referenceframe.Input) error {
+	defer vc.timeStage("alignment")()
+
+	alignPlan, err := vc.planMotion(ctx, "bottle-to-cup-align", alignReq)
+	if err != nil {
+		return fmt.Errorf("failed to plan bottle alignment: %w", err)
+	}
+	for i, step := range alignPlan.Trajectory() {
+		vc.logger.Infof("[bottle-to-cup-align] plan step %d: %v", i, step[vc.conf.BottleArm])
+	}
+	if len(alignPlan.Trajectory()) != 2 {
+		return fmt.Errorf("[bottle-to-cup-align] unexpected trajectory length (%d)", len(alignPlan.Trajectory()))
+	}
+	maxAlignTries := profile.alignTries()
+	var lastErr error
+	for try := 1; try <= maxAlignTries; try++ {
+		vc.recordCycle(func(r *cycleRecord) {
+			r.AlignTries++
+		})
+		if try > 1 {
+			vc.metrics.inc(vc.metrics.alignRetries)
+		}
+		alignGoalJoints := alignPlan.Trajectory()[1][vc.conf.BottleArm]
+		alignL2 := referenceframe.InputsL2Distance(alignJoints, alignGoalJoints)
+		vc.logger.Infof("[bottle-to-cup-align][try %d] InputsL2Distance: %v", try, alignL2)
+		if alignL2 > profile.maxAlignL2() {
+			if mkErr := os.MkdirAll(planDir, 0o755); mkErr != nil {
+				vc.logger.Errorf("[bottle-to-cup-align][try %d] failed to create %s: %v", try, planDir, mkErr)
+			}
+			fn := fmt.Sprintf("%s/align-plan-bad-try-%d-%d.json", planDir, try, time.Now().Unix())
+			debugErr := alignReq.WriteToFile(fn)
+			if debugErr != nil {
+				vc.logger.Errorf("[bottle-to-cup-align][try %d] failed to write debug: %v", try, debugErr)
+			} else {
+				vc.logger.Warnf("[bottle-to-cup-align][try %d] debug written to %s", try, fn)
+			}
+			lastErr = fmt.Errorf("[bottle-to-cup-align][try %d] pos too far: %v", try, alignL2)
+			// On all but the final try, replan
+			if try < maxAlignTries {
+				vc.logger.Warnf("[bottle-to-cup-align][try %d] L2 too high, replanning...", try)
+				alignPlan, err = vc.planMotion(ctx, "bottle-to-cup-align", alignReq)
+				if err != nil {
+					return fmt.Errorf("failed to replan bottle alignment on try %d: %w", try, err)
+				}
+				continue
+			} else {
+				return lastErr
+			}
+		}
+		vc.logger.Infof("[bottle-to-cup-align][try %d] moving bottle arm to align with cup target", try)
+		err = vc.moveArm(ctx, vc.c.BottleArm, "bottle-to-cup-align", alignGoalJoints)
+		if err != nil {
+			lastErr = fmt.Errorf("failed to align bottle to cup on try %d: %w", try, err)
+			// the same plan would be rejected again
+			if errors.Is(err, errBadTrajectory) && try < maxAlignTries {
+				vc.logger.Warnf("[bottle-to-cup-align][try %d] %v, replanning...", try, err)
+				alignPlan, err = vc.planMotion(ctx, "bottle-to-cup-align", alignReq)
+				if err != nil {
+					return fmt.Errorf("failed to replan bottle alignment on try %d: %w", try, err)
+				}
+			}
+			continue
+		}
+		// Success!
+		lastErr = nil
+		break
+	}
+	return lastErr
+}
+
 func (vc *VinoCart) CancelPour() error {
 	if vc.cancelPour == nil {
 		return fmt.Errorf("no pour in progress")
@@ -1264,13 +1670,24 @@ func saveImage(img image.Image, dirName string, loopNumber int) (string, error)
 }
 
 func (vc *VinoCart) PutBack(ctx context.Context) error {
-	vc.setStatus("placing")
+	return vc.putBack(ctx, nil)
+}
+
+// putBack sets the cup down below where put-back/before-open leaves it, or
+// where it was picked up from if spot is set.
+func (vc *VinoCart) putBack(ctx context.Context, spot *cupSpot) error {
+	vc.setStatus(StatePlacing)
+	defer vc.timeStage("put_back")()
 	err := vc.doAll(ctx, "put-back", "before-open", 50)
 	if err != nil {
 		return err
 	}
 
-	err = vc.moveToCurrentXYAtCupHeight(ctx)
+	if spot == nil {
+		err = vc.moveToCurrentXYAtCupHeight(ctx)
+	} else {
+		err = vc.moveToCupSpot(ctx, spot)
+	}
 	if err != nil {
 		return err
 	}
@@ -1287,16 +1704,24 @@ func (vc *VinoCart) PutBack(ctx context.Context) error {
 
 	time.Sleep(time.Millisecond * 500)
 
-	return vc.doAll(ctx, "put-back", "post-open", 100)
-}
+	if spot != nil {
+		err = vc.retreatFromCupSpot(ctx, spot)
+		if err != nil {
+			return err
+		}
+	}
 
-func (vc *VinoCart) PourMotionDemo(ctx context.Context, pp *PourPositions) error {
-	err := SetXarmSpeed(ctx, vc.c.BottleArm, 75, 75)
+	err = vc.doAll(ctx, "put-back", "post-open", 100)
 	if err != nil {
 		return err
 	}
 
-	err = vc.doAll(ctx, "pour", "prep", 75)
+	vc.markStageDone(ctx, stagePutBack)
+	return nil
+}
+
+func (vc *VinoCart) PourMotionDemo(ctx context.Context, pp *PourPositions) error {
+	err := vc.doAll(ctx, "pour", "prep", 75)
 	if err != nil {
 		return err
 	}
@@ -1325,13 +1750,14 @@ func (vc *VinoCart) PourMotionDemo(ctx context.Context, pp *PourPositions) error
 }
 
 func (vc *VinoCart) doPourMotion(ctx, pourContext context.Context, pp *PourPositions) error {
-	err := SetXarmSpeed(ctx, vc.c.BottleArm, 20, 50)
+	err := vc.setBottleArmSpeed(ctx, speedPourTilt, ArmSpeed{Speed: 20, Accel: 50})
 	if err != nil {
 		return err
 	}
-	defer SetXarmSpeedLog(ctx, vc.c.BottleArm, 50, 50, vc.logger)
+	defer vc.setBottleArmSpeedLog(ctx, speedDefault, both(50))
 
-	err = vc.c.BottleArm.MoveThroughJointPositions(pourContext, pp.joints, nil, nil)
+	vc.setPoured(true)
+	err = vc.moveArm(pourContext, vc.c.BottleArm, "pour-tilt", pp.joints...)
 
 	if err != nil && err != context.Canceled && pourContext.Err() != context.Canceled {
 		return err
@@ -1349,7 +1775,7 @@ func (vc *VinoCart) doPourMotion(ctx, pourContext context.Context, pp *PourPosit
 		return err
 	}
 
-	err = SetXarmSpeed(ctx, vc.c.BottleArm, 100, 100)
+	err = vc.setBottleArmSpeed(ctx, speedPourReturn, both(100))
 	if err != nil {
 		return err
 	}
@@ -1364,7 +1790,7 @@ func (vc *VinoCart) doPourMotion(ctx, pourContext context.Context, pp *PourPosit
 		posesToDo = append(posesToDo, pp.joints[i])
 	}
 
-	return vc.c.BottleArm.MoveThroughJointPositions(ctx, posesToDo, nil, nil)
+	return vc.moveArm(ctx, vc.c.BottleArm, "pour-return", posesToDo...)
 }
 
 type PourPositions struct {
@@ -1372,7 +1798,21 @@ type PourPositions struct {
 	poses  []*referenceframe.PoseInFrame
 }
 
+// bottleAlignTarget is where the bottle top goes to pour: gap mm above the cup
+// top, keeping the bottle's current orientation.
+func bottleAlignTarget(cupTop, bottleTop spatialmath.Pose, gap float64) *referenceframe.PoseInFrame {
+	gapOffset := r3.Vector{Z: gap}
+	return referenceframe.NewPoseInFrame("world",
+		spatialmath.NewPose(cupTop.Point().Add(gapOffset), bottleTop.Orientation()),
+	)
+}
+
 func (vc *VinoCart) SetupPourPositions(ctx context.Context) (*PourPositions, error) {
+	return vc.setupPourPositions(ctx, vc.conf.pourProfile())
+}
+
+func (vc *VinoCart) setupPourPositions(ctx context.Context, profile PourProfile) (*PourPositions, error) {
+	defer vc.timeStage("setup_pour_positions")()
 	myFs, err := touch.FrameSystemWithSomeParts(ctx, vc.c.Rfs, []string{vc.conf.BottleArm, vc.conf.BottleGripper}, vc.pourExtraFrames)
 	if err != nil {
 		return nil, err
@@ -1391,20 +1831,83 @@ func (vc *VinoCart) SetupPourPositions(ctx context.Context) (*PourPositions, err
 	bottleStart := bottleTopNow.Pose()
 	vc.logger.Infof("bottleStart (current bottle-top in world): %v", bottleStart)
 
+	// the start is nearly the same every pour, so the tilt usually is too
+	fingerprint, err := tiltFingerprint(myFs, vc.obstacles)
+	if err != nil {
+		vc.logger.Warnf("not using the tilt cache: %v", err)
+		return vc.planPourPositions(ctx, "pour-tilt", profile, myFs, startJoints, bottleStart)
+	}
+	key := tiltCacheKey(profile, startJoints, bottleStart)
+
+	e, result, err := vc.tiltCache.get(fingerprint, key)
+	if err != nil {
+		vc.logger.Warnf("tilt cache: %v", err)
+	}
+	if e != nil {
+		poses := pourTiltPoses(profile, bottleStart)
+		err = e.check(startJoints, poses, profile.maxStepL2())
+		if err == nil {
+			vc.metrics.inc(vc.metrics.tiltCache, tiltCacheHit)
+			vc.logger.Infof("using cached tilt %s", key)
+			return &PourPositions{joints: e.Joints, poses: poses}, nil
+		}
+		vc.logger.Infof("cached tilt %s no good: %v", key, err)
+		result = tiltCacheRejected
+	}
+	vc.metrics.inc(vc.metrics.tiltCache, result)
+
+	pp, err := vc.planPourPositions(ctx, "pour-tilt", profile, myFs, startJoints, bottleStart)
+	if err != nil {
+		return nil, err
+	}
+	err = vc.tiltCache.put(fingerprint, key, &tiltCacheEntry{StartJoints: startJoints, Joints: pp.joints})
+	if err != nil {
+		vc.logger.Warnf("can't save tilt: %v", err)
+	}
+	return pp, nil
+}
+
+// pourTiltPoses are the bottle top poses of the pour tilt, from bottleStart down
+// to the profile's tilt stop.
+func pourTiltPoses(profile PourProfile, bottleStart spatialmath.Pose) []*referenceframe.PoseInFrame {
 	o := bottleStart.Orientation().OrientationVectorDegrees()
 
-	joints := [][]referenceframe.Input{}
 	poses := []*referenceframe.PoseInFrame{}
 
 	pDelta := r3.Vector{}
-	for o.OZ > -.5 {
-		goalPose := referenceframe.NewPoseInFrame("world",
+	drift := profile.drift()
+	for o.OZ > profile.tiltStop() {
+		poses = append(poses, referenceframe.NewPoseInFrame("world",
 			spatialmath.NewPose(
 				bottleStart.Point().Add(pDelta),
 				o,
 			),
-		)
+		))
+
+		o.OZ -= profile.tiltStep()
+		pDelta = pDelta.Add(drift)
+	}
+	return poses
+}
+
+// planPourPositions plans the bottle arm through the pour tilt, one small step at a time,
+// starting from startJoints with the bottle top at bottleStart.
+func (vc *VinoCart) planPourPositions(ctx context.Context, tag string, profile PourProfile, myFs *referenceframe.FrameSystem, startJoints []referenceframe.Input, bottleStart spatialmath.Pose) (*PourPositions, error) {
+	worldState, err := vc.planWorldState(ctx, myFs)
+	if err != nil {
+		return nil, err
+	}
+
+	goals := pourTiltPoses(profile, bottleStart)
+	if len(goals) == 0 {
+		return nil, fmt.Errorf("bottle starts at OZ %.2f, already past tilt_stop %.2f",
+			bottleStart.Orientation().OrientationVectorDegrees().OZ, profile.tiltStop())
+	}
 
+	joints := [][]referenceframe.Input{}
+	poses := []*referenceframe.PoseInFrame{}
+
+	for _, goalPose := range goals {
 		vc.logger.Infof(" next: %v", goalPose.Pose())
 
 		vc.logger.Infof("myFs %v", myFs)
@@ -1417,14 +1920,15 @@ func (vc *VinoCart) SetupPourPositions(ctx context.Context) (*PourPositions, err
 			StartState: armplanning.NewPlanState(nil, referenceframe.FrameSystemInputs{
 				vc.conf.BottleArm: startJoints,
 			}),
+			WorldState: worldState,
 		}
-		plan, _, err := armplanning.PlanMotion(ctx, vc.logger, req)
+		plan, err := vc.planMotion(ctx, tag, req)
 		if err != nil {
 			return nil, fmt.Errorf("can't plan pour prep: %w", err)
 		}
 
 		for i, step := range plan.Trajectory() {
-			vc.logger.Infof("[pour-tilt] plan step %d: %v", i, step[vc.conf.BottleArm])
+			vc.logger.Infof("[%s] plan step %d: %v", tag, i, step[vc.conf.BottleArm])
 		}
 		if len(plan.Trajectory()) != 2 {
 			return nil, fmt.Errorf("why is plan wrong (%d)\n %v", len(plan.Trajectory()), plan)
@@ -1436,7 +1940,7 @@ func (vc *VinoCart) SetupPourPositions(ctx context.Context) (*PourPositions, err
 		if len(joints) > 0 {
 			d := referenceframe.InputsL2Distance(startJoints, myJoints)
 			vc.logger.Infof("\t InputsL2Distance: %v", d)
-			if d > 0.2 {
+			if d > profile.maxStepL2() {
 				if mkErr := os.MkdirAll(planDir, 0o755); mkErr != nil {
 					vc.logger.Errorf("failed to create %s: %v", planDir, mkErr)
 				}
@@ -1451,12 +1955,6 @@ func (vc *VinoCart) SetupPourPositions(ctx context.Context) (*PourPositions, err
 		poses = append(poses, goalPose)
 		joints = append(joints, myJoints)
 		startJoints = myJoints
-
-		o.OZ -= .05
-		pDelta.Z -= 1.0
-		pDelta.Y -= .5
-		pDelta.X += .5
-
 	}
 
 	if len(joints) != len(poses) {
@@ -1466,20 +1964,41 @@ func (vc *VinoCart) SetupPourPositions(ctx context.Context) (*PourPositions, err
 	return &PourPositions{joints: joints, poses: poses}, nil
 }
 
-func moveWithLinearConstraint(ctx context.Context, m motion.Service, n resource.Name, p *referenceframe.PoseInFrame, planTag string) error {
-	_, err := m.Move(
+// planMotion is armplanning.PlanMotion with the latency recorded under tag, and
+// the plan recorded for replanning.
+func (vc *VinoCart) planMotion(ctx context.Context, tag string, req *armplanning.PlanRequest) (motionplan.Plan, error) {
+	start := time.Now()
+	plan, _, err := armplanning.PlanMotion(ctx, vc.logger, req)
+	vc.metrics.observePlan(tag, start, err)
+
+	r := newPlanRecord(tag, planSourceArmplanning, start, err)
+	if err == nil {
+		r.Trajectory = plan.Trajectory()
+	}
+	vc.plans.record(r, req)
+	return plan, err
+}
+
+func moveWithLinearConstraint(ctx context.Context, m motion.Service, n resource.Name, p *referenceframe.PoseInFrame, obstacles []*referenceframe.GeometriesInFrame, extra map[string]interface{}) error {
+	worldState, err := referenceframe.NewWorldState(obstacles, nil)
+	if err != nil {
+		return err
+	}
+	_, err = m.Move(
 		ctx,
 		motion.MoveReq{
 			ComponentName: n.ShortName(),
 			Destination:   p,
+			WorldState:    worldState,
 			Constraints:   &LinearConstraint,
-			Extra:         planTagExtra(planTag),
+			Extra:         extra,
 		},
 	)
 	return err
 }
 
 func (vc *VinoCart) FindCups(ctx context.Context) ([]*viz.Object, error) {
+	defer vc.timeStage("find_cups")()
 	objects, err := vc.c.CupFinder.GetObjectPointClouds(ctx, "", nil)
 	if err != nil {
 		return nil, err
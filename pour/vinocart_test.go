@@ -0,0 +1,503 @@
+package pour
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang/geo/r3"
+	"go.viam.com/rdk/logging"
+	"go.viam.com/rdk/referenceframe"
+	"go.viam.com/rdk/resource"
+	"go.viam.com/rdk/spatialmath"
+	"go.viam.com/rdk/utils"
+	"go.viam.com/test"
+
+	"github.com/erh/vmodutils/touch"
+
+	"github.com/viam-modules/viam-pouring-demo/pour/sim"
+)
+
+// newSimVinoCart is a VinoCart on a simulated cart with nothing on the table.
+func newSimVinoCart(t *testing.T) (*VinoCart, *sim.Cart) {
+	t.Helper()
+	logger := logging.NewTestLogger(t)
+
+	// pour images are read before moving to a temp dir for the training images
+	pourImages := []image.Image{}
+	for i := 0; i < 10; i++ {
+		img, err := readImage(fmt.Sprintf("data/pour1/img-%d.png", i))
+		test.That(t, err, test.ShouldBeNil)
+		pourImages = append(pourImages, img)
+	}
+	dir := t.TempDir()
+	t.Chdir(dir)
+
+	cart, err := sim.NewCart(logger)
+	test.That(t, err, test.ShouldBeNil)
+
+	cart.AddPosition("cup-home", cart.Arm, sim.CupArmHome)
+	cart.AddPosition("cup-pour", cart.Arm, sim.CupArmPour)
+	cart.AddPosition("cup-place", cart.Arm, sim.CupArmPlace)
+	cart.AddPosition("bottle-home", cart.BottleArm, sim.BottleArmHome)
+	cart.AddPosition("bottle-pour", cart.BottleArm, sim.BottleArmPour)
+
+	cart.GlassPourCam.SetImages(pourImages...)
+	cart.GlassFinder.SetDetections(sim.NewDetection(pourImages[0].Bounds(), "glass"))
+
+	conf := &Config{
+		ArmName:              sim.CupArmName,
+		GripperName:          sim.CupGripperName,
+		CameraName:           sim.CameraName,
+		GlassPourCam:         sim.GlassPourCamName,
+		CupFinderService:     sim.CupFinderName,
+		PourGlassFindService: sim.GlassFinderName,
+		BottleArm:            sim.BottleArmName,
+		BottleGripper:        sim.BottleGripperName,
+		BottleHeight:         300,
+		CupHeight:            110,
+		CupWidth:             60,
+		JournalPath:          filepath.Join(dir, "journal.jsonl"),
+		TiltCachePath:        filepath.Join(dir, "tilt-cache.json"),
+		PlanRecordDir:        filepath.Join(dir, "plans"),
+		SpeedControl:         speedControlXarm,
+		Web:                  WebConfig{Disabled: true},
+		Positions: map[string]ConfigStatePostions{
+			"touch": {
+				"prep": {{"cup-home", "bottle-home"}},
+			},
+			"reset": {
+				"left-holding-pre":   {{"cup-place"}},
+				"left-holding-post":  {{"cup-home"}},
+				"right-holding-pre":  {{"bottle-home"}},
+				"right-holding-post": {{"bottle-home"}},
+			},
+			"pour_prep": {
+				"prep-grab":  {{"cup-pour"}},
+				"right-grab": {{"bottle-pour"}},
+				"post-grab":  {{"bottle-pour"}},
+			},
+			"pour": {
+				"prep":   {{"cup-pour", "bottle-pour"}},
+				"finish": {{"bottle-home"}},
+			},
+			"put-back": {
+				"before-open": {{"cup-place"}},
+				"post-open":   {{"cup-home", "bottle-home"}},
+			},
+		},
+	}
+	_, _, err = conf.Validate("")
+	test.That(t, err, test.ShouldBeNil)
+
+	c, err := Pour1ComponentsFromDependencies(conf, cart.Dependencies())
+	test.That(t, err, test.ShouldBeNil)
+
+	vc, err := NewVinoCart(context.Background(), conf, c, nil, nil, logger)
+	test.That(t, err, test.ShouldBeNil)
+	t.Cleanup(func() {
+		test.That(t, vc.Close(context.Background()), test.ShouldBeNil)
+	})
+
+	return vc, cart
+}
+
+func putCup(t *testing.T, cart *sim.Cart, x, y float64) {
+	t.Helper()
+	cup, err := sim.NewCup(x, y, 110, 60)
+	test.That(t, err, test.ShouldBeNil)
+	cart.CupFinder.SetObjects(cup)
+}
+
+func lastCycle(t *testing.T, vc *VinoCart) *cycleRecord {
+	t.Helper()
+	records, _, err := vc.journal.read(historyQuery{Limit: 1})
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, len(records), test.ShouldEqual, 1)
+	return records[0]
+}
+
+func TestSimReset(t *testing.T) {
+	ctx := context.Background()
+	vc, cart := newSimVinoCart(t)
+
+	cart.Gripper.SetHolding(true)
+	cart.BottleGripper.SetHolding(true)
+
+	test.That(t, vc.Reset(ctx), test.ShouldBeNil)
+
+	test.That(t, cart.Gripper.Holding(), test.ShouldBeFalse)
+	test.That(t, cart.BottleGripper.Holding(), test.ShouldBeFalse)
+	test.That(t, cart.Positions["cup-place"].GoTos(), test.ShouldEqual, 1)
+
+	joints, err := cart.Arm.JointPositions(ctx, nil)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, joints, test.ShouldResemble, sim.CupArmHome)
+}
+
+func TestSimSpeeds(t *testing.T) {
+	ctx := context.Background()
+	vc, cart := newSimVinoCart(t)
+	vc.conf.Speeds = map[string]SpeedProfile{
+		"touch/prep": {BottleArm: ArmSpeed{Speed: 30}},
+	}
+
+	test.That(t, vc.DoAll(ctx, "touch", "prep"), test.ShouldBeNil)
+	test.That(t, cart.Arm.Speed(), test.ShouldEqual, 50)
+	test.That(t, cart.BottleArm.Speed(), test.ShouldEqual, 30)
+
+	_, err := vc.DoCommand(ctx, map[string]interface{}{"set_speed_multiplier": 0.5})
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, vc.DoAll(ctx, "touch", "prep"), test.ShouldBeNil)
+	test.That(t, cart.Arm.Speed(), test.ShouldEqual, 25)
+	test.That(t, cart.BottleArm.Speed(), test.ShouldEqual, 15)
+
+	_, err = vc.DoCommand(ctx, map[string]interface{}{"set_speed_multiplier": 2.0})
+	test.That(t, err, test.ShouldNotBeNil)
+	test.That(t, vc.getSpeedMultiplier(), test.ShouldEqual, 0.5)
+}
+
+func TestSimSpeedControl(t *testing.T) {
+	ctx := context.Background()
+	vc, cart := newSimVinoCart(t)
+
+	// sim arms take set_speed like an xArm
+	test.That(t, vc.DoAll(ctx, "touch", "prep"), test.ShouldBeNil)
+	sc := vc.speedControlStatus()
+	test.That(t, sc[sim.CupArmName], test.ShouldEqual, speedControlXarm)
+	test.That(t, sc[sim.BottleArmName], test.ShouldEqual, speedControlXarm)
+	test.That(t, vc.moveExtra(cart.Arm, "x"), test.ShouldResemble, planTagExtra("x"))
+
+	// sim kinematics are named after the arm, not an arm model
+	vc.setSpeedControl(speedControlAuto)
+	test.That(t, vc.setBottleArmSpeed(ctx, speedPourTilt, ArmSpeed{Speed: 20, Accel: 50}), test.ShouldBeNil)
+	test.That(t, vc.moveArm(ctx, cart.BottleArm, "test", sim.BottleArmHome, sim.BottleArmPour), test.ShouldBeNil)
+	opts := cart.BottleArm.MoveOptions()
+	test.That(t, opts, test.ShouldNotBeNil)
+	test.That(t, opts.MaxVelRads, test.ShouldAlmostEqual, utils.DegToRad(20))
+	test.That(t, opts.MaxAccRads, test.ShouldAlmostEqual, utils.DegToRad(50))
+	test.That(t, vc.moveExtra(cart.BottleArm, "x")["max_vel_degs_per_sec"], test.ShouldEqual, 20.0)
+	test.That(t, vc.speedControlStatus()[sim.BottleArmName], test.ShouldEqual, speedControlGeneric)
+
+	// position switches don't take speeds, so the arm goes to the saved joints itself
+	gotos := cart.Positions["cup-home"].GoTos()
+	test.That(t, vc.DoAll(ctx, "touch", "prep"), test.ShouldBeNil)
+	test.That(t, cart.Positions["cup-home"].GoTos(), test.ShouldEqual, gotos)
+	test.That(t, cart.Arm.MoveOptions(), test.ShouldNotBeNil)
+	joints, err := cart.Arm.JointPositions(ctx, nil)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, joints, test.ShouldResemble, sim.CupArmHome)
+
+	// but a switch that plans with the motion service still moves itself
+	cart.Positions["cup-home"].SetMotion("builtin")
+	test.That(t, vc.DoAll(ctx, "touch", "prep"), test.ShouldBeNil)
+	test.That(t, cart.Positions["cup-home"].GoTos(), test.ShouldEqual, gotos+1)
+}
+
+func TestSpeedControlForModel(t *testing.T) {
+	test.That(t, speedControlForModel("xArm6"), test.ShouldEqual, speedControlXarm)
+	test.That(t, speedControlForModel("lite6"), test.ShouldEqual, speedControlXarm)
+	test.That(t, speedControlForModel("UR5e"), test.ShouldEqual, speedControlUR)
+	test.That(t, speedControlForModel("left-arm"), test.ShouldEqual, speedControlGeneric)
+}
+
+func TestSimCalibrate(t *testing.T) {
+	ctx := context.Background()
+	vc, cart := newSimVinoCart(t)
+
+	gripperPose, err := cart.Motion.GetPose(ctx, sim.BottleGripperName, "world", nil, nil)
+	test.That(t, err, test.ShouldBeNil)
+	tip := spatialmath.Compose(gripperPose.Pose(), spatialmath.NewPoseFromPoint(r3.Vector{X: 200, Y: -5, Z: 3}))
+
+	res, err := vc.DoCommand(ctx, map[string]interface{}{
+		"calibrate": map[string]interface{}{
+			"frame":     bottleName,
+			"reference": vectorMap(tip.Point()),
+		},
+	})
+	test.That(t, err, test.ShouldBeNil)
+	measured := res["measured"].(map[string]interface{})
+	test.That(t, measured["x"], test.ShouldAlmostEqual, 200, .01)
+	test.That(t, measured["y"], test.ShouldAlmostEqual, -5, .01)
+	test.That(t, measured["z"], test.ShouldAlmostEqual, 3, .01)
+	test.That(t, res["current"], test.ShouldResemble, vectorMap(r3.Vector{X: 230, Y: -7}))
+
+	_, err = vc.DoCommand(ctx, map[string]interface{}{
+		"calibrate": map[string]interface{}{"frame": bottleName, "use_cup_finder": true},
+	})
+	test.That(t, err, test.ShouldNotBeNil)
+}
+
+func TestSimReconfigure(t *testing.T) {
+	ctx := context.Background()
+	vc, cart := newSimVinoCart(t)
+	rc := func(c Config) error {
+		return vc.Reconfigure(ctx, cart.Dependencies(), resource.Config{Name: "vc", ConvertedAttributes: &c})
+	}
+
+	c := *vc.conf
+	c.CupHeight = 120
+	c.SpeedMultiplier = .5
+	c.BottleTopOffset = &r3.Vector{X: 100}
+	test.That(t, rc(c), test.ShouldBeNil)
+	test.That(t, vc.conf.CupHeight, test.ShouldEqual, 120)
+	test.That(t, vc.getSpeedMultiplier(), test.ShouldEqual, .5)
+	test.That(t, vc.bottleTop.Pose().Point(), test.ShouldResemble, r3.Vector{X: 100})
+
+	// same switches, used for a different step
+	c.Positions = map[string]ConfigStatePostions{}
+	for k, v := range vc.conf.Positions {
+		c.Positions[k] = v
+	}
+	c.Positions["touch"] = ConfigStatePostions{"prep": {{"cup-pour", "bottle-home"}}}
+	test.That(t, rc(c), test.ShouldBeNil)
+	prep, err := vc.getPositions("touch", "prep")
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, prep[0][0].Name().ShortName(), test.ShouldEqual, "cup-pour")
+
+	c.ArmName = "other-arm"
+	err = rc(c)
+	test.That(t, resource.IsMustRebuildError(err), test.ShouldBeTrue)
+	test.That(t, vc.conf.ArmName, test.ShouldEqual, sim.CupArmName)
+}
+
+func TestSimWorldState(t *testing.T) {
+	ctx := context.Background()
+	vc, cart := newSimVinoCart(t)
+
+	c := *vc.conf
+	c.Obstacles = []Obstacle{{Name: "table", Type: obstacleBox, Translation: r3.Vector{Z: -10}, DimsMM: &r3.Vector{X: 1000, Y: 600, Z: 20}}}
+	test.That(t, vc.Reconfigure(ctx, cart.Dependencies(), resource.Config{Name: "vc", ConvertedAttributes: &c}), test.ShouldBeNil)
+
+	putCup(t, cart, 500, 0)
+	res, err := vc.DoCommand(ctx, map[string]interface{}{"world_state": true, "cups": true})
+	test.That(t, err, test.ShouldBeNil)
+	obstacles := res["obstacles"].([]map[string]interface{})
+	test.That(t, len(obstacles), test.ShouldEqual, 2)
+	test.That(t, obstacles[0]["label"], test.ShouldEqual, "table")
+	test.That(t, obstacles[0]["frame"], test.ShouldEqual, "world")
+	test.That(t, obstacles[0]["type"], test.ShouldEqual, obstacleBox)
+	test.That(t, obstacles[0]["dims_mm"], test.ShouldResemble, vectorMap(r3.Vector{X: 1000, Y: 600, Z: 20}))
+	test.That(t, obstacles[1]["label"], test.ShouldEqual, "cup-0")
+
+	c.Obstacles = append(c.Obstacles, Obstacle{Name: "table", Type: obstacleCapsule, RadiusMM: 10, LengthMM: 100})
+	_, _, err = c.Validate("")
+	test.That(t, err, test.ShouldNotBeNil)
+	c.Obstacles = []Obstacle{{Name: "screen", Type: "cone"}}
+	_, _, err = c.Validate("")
+	test.That(t, err, test.ShouldNotBeNil)
+}
+
+func TestSimPlanWorldState(t *testing.T) {
+	ctx := context.Background()
+	vc, cart := newSimVinoCart(t)
+
+	c := *vc.conf
+	c.Obstacles = []Obstacle{{Name: "shield", Type: obstacleBox, Frame: sim.BottleArmName, DimsMM: &r3.Vector{X: 10, Y: 10, Z: 10}}}
+	test.That(t, vc.Reconfigure(ctx, cart.Dependencies(), resource.Config{Name: "vc", ConvertedAttributes: &c}), test.ShouldBeNil)
+
+	// planning the cup arm alone still avoids what's on the bottle arm
+	fs, err := touch.FrameSystemWithSomeParts(ctx, vc.c.Rfs, []string{sim.CupArmName, sim.CupGripperName}, nil)
+	test.That(t, err, test.ShouldBeNil)
+	ws, err := vc.planWorldState(ctx, fs)
+	test.That(t, err, test.ShouldBeNil)
+	obstacles, err := ws.ObstaclesInWorldFrame(fs, referenceframe.FrameSystemInputs{})
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, len(obstacles.Geometries()), test.ShouldEqual, 1)
+
+	armPose, err := cart.Motion.GetPose(ctx, sim.BottleArmName, referenceframe.World, nil, nil)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, spatialmath.PoseAlmostCoincident(obstacles.Geometries()[0].Pose(), armPose.Pose()), test.ShouldBeTrue)
+}
+
+func TestSimRecipes(t *testing.T) {
+	ctx := context.Background()
+	vc, cart := newSimVinoCart(t)
+
+	c := *vc.conf
+	c.Recipes = map[string]Recipe{
+		"water": {BottleHeight: 250, PourProfile: &PourProfile{TotalSeconds: 5}},
+	}
+	test.That(t, vc.Reconfigure(ctx, cart.Dependencies(), resource.Config{Name: "vc", ConvertedAttributes: &c}), test.ShouldBeNil)
+
+	testPosition := func(recipe string) error {
+		cmd := map[string]interface{}{
+			"test_position": map[string]interface{}{"stage": "touch", "step": "prep"},
+			"blocking":      true,
+		}
+		if recipe != "" {
+			cmd["recipe"] = recipe
+		}
+		_, err := vc.DoCommand(ctx, cmd)
+		return err
+	}
+
+	test.That(t, testPosition("water"), test.ShouldBeNil)
+	status, err := vc.DoCommand(ctx, map[string]interface{}{"status": true})
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, status["recipe"], test.ShouldEqual, "water")
+	test.That(t, vc.conf.pourProfile().totalTime(), test.ShouldEqual, 5*time.Second)
+	test.That(t, vc.bottleTop.Pose().Point().X, test.ShouldEqual, 180)
+
+	test.That(t, testPosition(""), test.ShouldBeNil)
+	test.That(t, vc.activeRecipe(), test.ShouldEqual, "")
+	test.That(t, vc.conf.BottleHeight, test.ShouldEqual, 300)
+
+	err = testPosition("soda")
+	test.That(t, err, test.ShouldNotBeNil)
+	test.That(t, err.Error(), test.ShouldContainSubstring, "no recipe")
+}
+
+func TestSimFullDemo(t *testing.T) {
+	ctx := context.Background()
+	vc, cart := newSimVinoCart(t)
+	putCup(t, cart, 450, 0)
+
+	test.That(t, vc.FullDemo(ctx), test.ShouldBeNil)
+
+	test.That(t, cart.Gripper.Holding(), test.ShouldBeFalse)
+	test.That(t, vc.lastCompletedStage().stage, test.ShouldEqual, stagePutBack)
+
+	r := lastCycle(t, vc)
+	test.That(t, r.ApproachOrientation, test.ShouldNotBeNil)
+	test.That(t, r.Outcome, test.ShouldEqual, outcomeSuccess)
+	test.That(t, r.StopReason, test.ShouldNotBeEmpty)
+}
+
+func TestSimFullDemoErrors(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("no cups", func(t *testing.T) {
+		vc, _ := newSimVinoCart(t)
+		test.That(t, vc.FullDemo(ctx), test.ShouldEqual, noObjects)
+	})
+
+	t.Run("too many cups", func(t *testing.T) {
+		vc, cart := newSimVinoCart(t)
+		a, err := sim.NewCup(450, 0, 110, 60)
+		test.That(t, err, test.ShouldBeNil)
+		b, err := sim.NewCup(450, 200, 110, 60)
+		test.That(t, err, test.ShouldBeNil)
+		cart.CupFinder.SetObjects(a, b)
+
+		err = vc.FullDemo(ctx)
+		test.That(t, err, test.ShouldNotBeNil)
+		test.That(t, err.Error(), test.ShouldContainSubstring, "too many objects")
+	})
+
+	t.Run("no approach", func(t *testing.T) {
+		vc, cart := newSimVinoCart(t)
+		c := *vc.conf
+		c.ApproachTimeoutSeconds = 2
+		c.Obstacles = []Obstacle{{Name: "wall", Type: obstacleBox, Translation: r3.Vector{X: 450, Z: 100}, DimsMM: &r3.Vector{X: 400, Y: 400, Z: 400}}}
+		test.That(t, vc.Reconfigure(ctx, cart.Dependencies(), resource.Config{Name: "vc", ConvertedAttributes: &c}), test.ShouldBeNil)
+		putCup(t, cart, 450, 0)
+
+		err := vc.FullDemo(ctx)
+		test.That(t, err, test.ShouldNotBeNil)
+		test.That(t, err.Error(), test.ShouldContainSubstring, "no approach to the cup")
+		test.That(t, lastCycle(t, vc).FailedStage, test.ShouldEqual, "approach")
+	})
+
+	t.Run("missed the cup", func(t *testing.T) {
+		vc, cart := newSimVinoCart(t)
+		putCup(t, cart, 450, 0)
+		cart.Gripper.SetEmpty(true)
+
+		err := vc.FullDemo(ctx)
+		test.That(t, err, test.ShouldNotBeNil)
+		test.That(t, err.Error(), test.ShouldContainSubstring, "didn't get cup")
+		test.That(t, lastCycle(t, vc).Outcome, test.ShouldEqual, outcomeFailed)
+		test.That(t, vc.getStatus(), test.ShouldEqual, StatePicking)
+	})
+
+	t.Run("arm fault", func(t *testing.T) {
+		vc, cart := newSimVinoCart(t)
+		putCup(t, cart, 450, 0)
+		cart.Gripper.SetHolding(true)
+		cart.Arm.SetMoveError(errors.New("xarm error code 31"))
+
+		err := vc.Reset(ctx)
+		test.That(t, err, test.ShouldNotBeNil)
+		test.That(t, err.Error(), test.ShouldContainSubstring, "code 31")
+		test.That(t, cart.Gripper.Holding(), test.ShouldBeTrue)
+	})
+}
+
+func TestSimResumeAfterPutBackFails(t *testing.T) {
+	ctx := context.Background()
+	vc, cart := newSimVinoCart(t)
+	putCup(t, cart, 450, 0)
+
+	// fails after put-back/before-open has moved the arms away from the pour
+	cart.Motion.FailPlanTag("descend-cup-height", errors.New("no path"))
+	test.That(t, vc.FullDemo(ctx), test.ShouldNotBeNil)
+	test.That(t, lastCycle(t, vc).FailedStage, test.ShouldEqual, "put_back")
+	test.That(t, cart.Gripper.Holding(), test.ShouldBeTrue)
+
+	last, err := vc.trustedLastStage(ctx)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, last, test.ShouldEqual, stageNone)
+	test.That(t, vc.hasPoured(), test.ShouldBeTrue)
+
+	cart.Motion.FailPlanTag("descend-cup-height", nil)
+	test.That(t, vc.Resume(ctx), test.ShouldBeNil)
+	test.That(t, cart.Gripper.Holding(), test.ShouldBeFalse)
+
+	r := lastCycle(t, vc)
+	test.That(t, r.Command, test.ShouldEqual, "resume")
+	_, poured := r.StageMs["pour_loop"]
+	test.That(t, poured, test.ShouldBeFalse)
+	_, putBack := r.StageMs["put_back"]
+	test.That(t, putBack, test.ShouldBeTrue)
+
+	// the cup is down, resuming again doesn't pour into it either
+	test.That(t, vc.Resume(ctx), test.ShouldBeNil)
+	_, poured = lastCycle(t, vc).StageMs["pour_loop"]
+	test.That(t, poured, test.ShouldBeFalse)
+}
+
+func TestSimResumeChecksDrift(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("cup where touch left it", func(t *testing.T) {
+		vc, cart := newSimVinoCart(t)
+		putCup(t, cart, 450, 0)
+		test.That(t, vc.Touch(ctx), test.ShouldBeNil)
+
+		test.That(t, vc.Resume(ctx), test.ShouldBeNil)
+		_, reset := lastCycle(t, vc).StageMs["reset"]
+		test.That(t, reset, test.ShouldBeFalse)
+		test.That(t, cart.Gripper.Holding(), test.ShouldBeFalse)
+	})
+
+	t.Run("cup jogged since", func(t *testing.T) {
+		vc, cart := newSimVinoCart(t)
+		putCup(t, cart, 450, 0)
+		test.That(t, vc.Touch(ctx), test.ShouldBeNil)
+		joints, err := cart.Arm.JointPositions(ctx, nil)
+		test.That(t, err, test.ShouldBeNil)
+		joints[0] += .5
+		test.That(t, cart.Arm.MoveToJointPositions(ctx, joints, nil), test.ShouldBeNil)
+
+		// starts over instead of pouring from wherever the cup is
+		test.That(t, vc.Resume(ctx), test.ShouldBeNil)
+		_, reset := lastCycle(t, vc).StageMs["reset"]
+		test.That(t, reset, test.ShouldBeTrue)
+	})
+
+	t.Run("poured cup down, bottle still held", func(t *testing.T) {
+		vc, cart := newSimVinoCart(t)
+		vc.setPoured(true)
+		cart.BottleGripper.SetHolding(true)
+
+		test.That(t, vc.Resume(ctx), test.ShouldBeNil)
+		test.That(t, cart.BottleGripper.Holding(), test.ShouldBeFalse)
+		_, poured := lastCycle(t, vc).StageMs["pour_loop"]
+		test.That(t, poured, test.ShouldBeFalse)
+	})
+}
@@ -0,0 +1,174 @@
+package pour
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"go.uber.org/multierr"
+
+	"go.viam.com/rdk/logging"
+)
+
+const defaultWebPort = 9999
+
+// WebConfig is where the vinoweb app is served.
+type WebConfig struct {
+	Disabled bool `json:"disabled,omitempty"`
+	// address to bind to, all interfaces if unset
+	Host string `json:"host,omitempty"`
+	Port int    `json:"port,omitempty"`
+
+	// serve https if both are set
+	TLSCertFile string `json:"tls_cert_file,omitempty"`
+	TLSKeyFile  string `json:"tls_key_file,omitempty"`
+}
+
+func (wc *WebConfig) Validate(path string) error {
+	if wc.Port < 0 || wc.Port > 65535 {
+		return fmt.Errorf("%s.port must be between 0 and 65535, not %d", path, wc.Port)
+	}
+	if (wc.TLSCertFile == "") != (wc.TLSKeyFile == "") {
+		return fmt.Errorf("%s needs both tls_cert_file and tls_key_file, or neither", path)
+	}
+	return nil
+}
+
+func (wc *WebConfig) addr() string {
+	port := wc.Port
+	if port == 0 {
+		port = defaultWebPort
+	}
+	return net.JoinHostPort(wc.Host, strconv.Itoa(port))
+}
+
+func (wc *WebConfig) tls() bool {
+	return wc.TLSCertFile != ""
+}
+
+// webServer serves handler and keeps track of whether that's working.
+type webServer struct {
+	handler http.Handler
+	logger  logging.Logger
+
+	lock   sync.Mutex
+	conf   WebConfig
+	server *http.Server
+	addr   string // what we're actually listening on
+	err    error
+	done   chan struct{}
+}
+
+func newWebServer(handler http.Handler, logger logging.Logger) *webServer {
+	return &webServer{handler: handler, logger: logger}
+}
+
+// start begins serving with conf. Failing to bind is logged and shown in status,
+// not returned, so the cart still works without its web app.
+func (ws *webServer) start(conf WebConfig) {
+	ws.lock.Lock()
+	defer ws.lock.Unlock()
+
+	ws.conf = conf
+	ws.err = nil
+	ws.addr = ""
+	if conf.Disabled {
+		ws.logger.Infof("web server disabled")
+		return
+	}
+
+	ln, err := net.Listen("tcp", conf.addr())
+	if err != nil {
+		ws.err = err
+		ws.logger.Errorf("web server can't listen on %s: %v", conf.addr(), err)
+		return
+	}
+
+	server := &http.Server{Handler: ws.handler}
+	done := make(chan struct{})
+	ws.server = server
+	ws.addr = ln.Addr().String()
+	ws.done = done
+	ws.logger.Infof("web listening on %s", ws.addr)
+
+	go func() {
+		defer close(done)
+		var err error
+		if conf.tls() {
+			err = server.ServeTLS(ln, conf.TLSCertFile, conf.TLSKeyFile)
+		} else {
+			err = server.Serve(ln)
+		}
+		if err != nil && !errors.Is(err, http.ErrServerClosed) {
+			ws.logger.Errorf("web server on %s stopped: %v", ln.Addr(), err)
+			ws.lock.Lock()
+			if ws.server == server {
+				ws.err = err
+			}
+			ws.lock.Unlock()
+		}
+	}()
+}
+
+// stop shuts down gracefully, giving open requests until ctx is done.
+func (ws *webServer) stop(ctx context.Context) error {
+	ws.lock.Lock()
+	server, done := ws.server, ws.done
+	ws.server, ws.done = nil, nil
+	ws.addr = ""
+	ws.lock.Unlock()
+
+	if server == nil {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	err := server.Shutdown(ctx)
+	if err != nil {
+		err = multierr.Combine(err, server.Close())
+	}
+	<-done
+	return err
+}
+
+// restart moves to conf if it's different, or tries again if it failed to start.
+func (ws *webServer) restart(ctx context.Context, conf WebConfig) error {
+	ws.lock.Lock()
+	fine := ws.conf == conf && (ws.server != nil || conf.Disabled)
+	ws.lock.Unlock()
+	if fine {
+		return nil
+	}
+
+	err := ws.stop(ctx)
+	ws.start(conf)
+	return err
+}
+
+func (ws *webServer) status() map[string]interface{} {
+	ws.lock.Lock()
+	defer ws.lock.Unlock()
+
+	m := map[string]interface{}{
+		"enabled":   !ws.conf.Disabled,
+		"listening": ws.server != nil && ws.err == nil,
+	}
+	if ws.addr != "" {
+		m["address"] = ws.addr
+		scheme := "http"
+		if ws.conf.tls() {
+			scheme = "https"
+		}
+		m["url"] = scheme + "://" + ws.addr
+	}
+	if ws.err != nil {
+		m["error"] = ws.err.Error()
+	}
+	return m
+}
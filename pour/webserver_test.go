@@ -0,0 +1,50 @@
+package pour
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"testing"
+
+	"go.viam.com/rdk/logging"
+	"go.viam.com/test"
+)
+
+func TestWebServer(t *testing.T) {
+	ctx := context.Background()
+	logger := logging.NewTestLogger(t)
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "vino")
+	})
+
+	// something else already has the port
+	taken, err := net.Listen("tcp", "127.0.0.1:0")
+	test.That(t, err, test.ShouldBeNil)
+	defer taken.Close()
+	port := taken.Addr().(*net.TCPAddr).Port
+
+	ws := newWebServer(handler, logger)
+	ws.start(WebConfig{Host: "127.0.0.1", Port: port})
+	s := ws.status()
+	test.That(t, s["listening"], test.ShouldBeFalse)
+	test.That(t, s["error"], test.ShouldContainSubstring, "address already in use")
+
+	taken.Close()
+	test.That(t, ws.restart(ctx, WebConfig{Host: "127.0.0.1", Port: port}), test.ShouldBeNil)
+	s = ws.status()
+	test.That(t, s["listening"], test.ShouldBeTrue)
+	test.That(t, s["error"], test.ShouldBeNil)
+
+	res, err := http.Get(s["url"].(string))
+	test.That(t, err, test.ShouldBeNil)
+	body, err := io.ReadAll(res.Body)
+	res.Body.Close()
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, string(body), test.ShouldEqual, "vino")
+
+	test.That(t, ws.stop(ctx), test.ShouldBeNil)
+	test.That(t, ws.status()["listening"], test.ShouldBeFalse)
+	_, err = http.Get(s["url"].(string))
+	test.That(t, err, test.ShouldNotBeNil)
+}